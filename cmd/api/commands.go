@@ -0,0 +1,750 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"sbom-serv/internal/auth"
+	"sbom-serv/internal/config"
+	"sbom-serv/internal/pkgindex"
+	"sbom-serv/internal/policy"
+	"sbom-serv/internal/project"
+	"sbom-serv/internal/taskstore"
+	"sbom-serv/internal/vulndb"
+	"sbom-serv/internal/vulnwatch"
+	"sbom-serv/internal/webhook"
+)
+
+const usage = `usage:
+  sbom-serv [options]                           run the API server
+  sbom-serv [options] <command> ...             run a management command
+  sbom-serv apikey create [-tenant T] [-roles R] <name>
+                                                create an API key (printed once);
+                                                R — comma-separated uploader,reader,admin,monitor
+  sbom-serv apikey list                         list API keys
+  sbom-serv apikey revoke <id>                  revoke an API key
+  sbom-serv tenant set [flags] <tenant>         set tenant retention and quotas
+      -retention D    keep done/failed tasks for D (e.g. 72h); 0 = janitor default
+      -max-tasks N    keep at most N done/failed tasks; 0 = unlimited
+      -max-active N   allow at most N queued/running tasks; 0 = unlimited
+      -max-versions N keep SBOMs of the N latest versions of each project past
+                      retention; they count toward -max-tasks; 0 = default (100)
+  sbom-serv tenant list                         list tenant settings
+  sbom-serv vulndb import [-full] [-version V] <path>...
+                                                load OSV/GHSA JSON records from files, directories
+                                                or OSV all.zip archives; records not newer than
+                                                the stored ones are skipped unless -full is set;
+                                                V names the snapshot (default: latest modified)
+  sbom-serv vulndb status                       show the current vulnerability DB snapshot
+  sbom-serv vulndb rematch [-tenant T] [-all] [-pinned] [id...]
+                                                re-match stored SBOMs of done tasks against the
+                                                current snapshot without re-scanning; by default
+                                                only tasks matched against an older snapshot;
+                                                new vulnerabilities of pinned tasks become alerts
+  sbom-serv packages reindex [-tenant T] [-all] [id...]
+                                                index packages of stored SBOMs for
+                                                GET /packages/search; by default only
+                                                done tasks that were never indexed
+  sbom-serv projects reindex [-tenant T] [id...]
+                                                register done tasks in their projects and
+                                                versions; by default only tasks missed there
+                                                (a version never moves to an older task)
+  sbom-serv policy set [-tenant T] [-project P] <file>
+                                                set the policy (YAML or JSON) of project P;
+                                                without -project — the tenant default policy
+  sbom-serv policy show [-tenant T] [-project P]
+                                                show the policy applied to project P
+  sbom-serv policy list [-tenant T]             list policies
+  sbom-serv policy delete [-tenant T] [-project P]
+                                                delete a policy
+  sbom-serv policy reevaluate [-tenant T] [-project P] [id...]
+                                                re-check stored SBOMs of done tasks against the
+                                                current policy; without -project — all projects
+
+options:
+  -config FILE      YAML config file (env SBOM_CONFIG)
+  -print-config     print effective configuration with secrets redacted and exit
+  -<key> VALUE      override a config key, e.g. -server.addr :9090 -worker.parallel 8;
+                    every key can also be set via env SBOM_<KEY>, e.g. SBOM_WORKER_PARALLEL
+`
+
+func runCommand(args []string, cfg config.Config) int {
+	var err error
+	switch args[0] {
+	case "apikey":
+		err = runAPIKey(args[1:], cfg)
+	case "tenant":
+		err = runTenant(args[1:], cfg)
+	case "vulndb":
+		err = runVulnDB(args[1:], cfg)
+	case "policy":
+		err = runPolicy(args[1:], cfg)
+	case "packages":
+		err = runPackages(args[1:], cfg)
+	case "projects":
+		err = runProjects(args[1:], cfg)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return 0
+	default:
+		err = fmt.Errorf("unknown command %q", args[0])
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		if errors.Is(err, errUsage) {
+			fmt.Fprint(os.Stderr, usage)
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+var errUsage = errors.New("invalid arguments")
+
+func runAPIKey(args []string, cfg config.Config) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	db, err := openDB(ctx, cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	keys := auth.NewKeyStore(db)
+
+	switch args[0] {
+	case "create":
+		fs := flag.NewFlagSet("apikey create", flag.ContinueOnError)
+		tenant := fs.String("tenant", config.DefaultTenant, "tenant of the key")
+		rolesFlag := fs.String("roles", strings.Join(auth.DefaultKeyRoles, ","), "comma-separated roles")
+		if err := fs.Parse(args[1:]); err != nil || fs.NArg() != 1 {
+			return errUsage
+		}
+		roles, err := auth.ParseRoles(*rolesFlag)
+		if err != nil {
+			return err
+		}
+		k, secret, err := keys.Create(ctx, fs.Arg(0), *tenant, roles)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("id:     %s\nname:   %s\ntenant: %s\nroles:  %s\nkey:    %s\n",
+			k.ID, k.Name, k.Tenant, strings.Join(k.Roles, ","), secret)
+		fmt.Fprintln(os.Stderr, "store the key now: it cannot be shown again")
+		return nil
+
+	case "list":
+		list, err := keys.List(ctx, "")
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(list)
+
+	case "revoke":
+		if len(args) != 2 {
+			return errUsage
+		}
+		if err := keys.Revoke(ctx, "", strings.TrimSpace(args[1])); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("key %s not found or already revoked", args[1])
+			}
+			return err
+		}
+		fmt.Println("revoked", args[1])
+		return nil
+	}
+	return errUsage
+}
+
+func runTenant(args []string, cfg config.Config) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	db, err := openDB(ctx, cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "set":
+		fs := flag.NewFlagSet("tenant set", flag.ContinueOnError)
+		retention := fs.Duration("retention", 0, "retention of done/failed tasks")
+		maxTasks := fs.Int("max-tasks", 0, "max stored done/failed tasks")
+		maxActive := fs.Int("max-active", 0, "max queued/running tasks")
+		maxVersions := fs.Int("max-versions", 0, "versions of each project whose SBOMs are kept")
+		if err := fs.Parse(args[1:]); err != nil || fs.NArg() != 1 {
+			return errUsage
+		}
+		tenant := fs.Arg(0)
+		if !config.ValidTenant(tenant) {
+			return fmt.Errorf("invalid tenant %q", tenant)
+		}
+		_, err := db.ExecContext(ctx, `
+			INSERT INTO sbom_tenants(id, retention_seconds, max_tasks, max_active_tasks, max_versions)
+			VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), NULLIF($4, 0), NULLIF($5, 0))
+			ON CONFLICT (id) DO UPDATE
+			SET retention_seconds = EXCLUDED.retention_seconds,
+			    max_tasks = EXCLUDED.max_tasks,
+			    max_active_tasks = EXCLUDED.max_active_tasks,
+			    max_versions = EXCLUDED.max_versions
+		`, tenant, int64(retention.Seconds()), *maxTasks, *maxActive, *maxVersions)
+		if err != nil {
+			return err
+		}
+		fmt.Println("updated", tenant)
+		return nil
+
+	case "list":
+		rows, err := db.QueryContext(ctx, `
+			SELECT id, retention_seconds, max_tasks, max_active_tasks, max_versions
+			FROM sbom_tenants
+			ORDER BY id
+		`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		type tenantRow struct {
+			ID             string `json:"id"`
+			Retention      string `json:"retention,omitempty"`
+			MaxTasks       *int64 `json:"max_tasks,omitempty"`
+			MaxActiveTasks *int64 `json:"max_active_tasks,omitempty"`
+			MaxVersions    *int64 `json:"max_versions,omitempty"`
+		}
+		out := []tenantRow{}
+		for rows.Next() {
+			var tr tenantRow
+			var retention, maxTasks, maxActive, maxVersions sql.NullInt64
+			if err := rows.Scan(&tr.ID, &retention, &maxTasks, &maxActive, &maxVersions); err != nil {
+				return err
+			}
+			if retention.Valid {
+				tr.Retention = (time.Duration(retention.Int64) * time.Second).String()
+			}
+			if maxTasks.Valid {
+				tr.MaxTasks = &maxTasks.Int64
+			}
+			if maxActive.Valid {
+				tr.MaxActiveTasks = &maxActive.Int64
+			}
+			if maxVersions.Valid {
+				tr.MaxVersions = &maxVersions.Int64
+			}
+			out = append(out, tr)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+	return errUsage
+}
+
+func runVulnDB(args []string, cfg config.Config) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	// импорт полной выгрузки и повторное сопоставление занимают минуты: без таймаута
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	db, err := openDB(ctx, cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	vulns := vulndb.NewStore(db)
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	switch args[0] {
+	case "import":
+		fs := flag.NewFlagSet("vulndb import", flag.ContinueOnError)
+		full := fs.Bool("full", false, "rewrite records even if they are not newer")
+		version := fs.String("version", "", "snapshot name")
+		if err := fs.Parse(args[1:]); err != nil || fs.NArg() == 0 {
+			return errUsage
+		}
+		st, err := vulns.Import(ctx, fs.Args(), vulndb.ImportOptions{
+			Full:    *full,
+			Version: *version,
+			Warn:    func(msg string) { fmt.Fprintln(os.Stderr, "skipped:", msg) },
+		})
+		if err != nil {
+			if st.Snapshot != nil {
+				fmt.Fprintln(os.Stderr, "import did not finish; records saved so far are in a partial snapshot")
+				_ = enc.Encode(st)
+			}
+			return err
+		}
+		if st.Snapshot == nil {
+			fmt.Fprintln(os.Stderr, "vulnerability database is up to date")
+		}
+		return enc.Encode(st)
+
+	case "status":
+		if len(args) != 1 {
+			return errUsage
+		}
+		snap, err := vulns.CurrentSnapshot(ctx)
+		if err != nil {
+			return err
+		}
+		if snap == nil {
+			return errors.New("vulnerability database is empty: run vulndb import")
+		}
+		return enc.Encode(snap)
+
+	case "rematch":
+		fs := flag.NewFlagSet("vulndb rematch", flag.ContinueOnError)
+		tenant := fs.String("tenant", "", "only tasks of this tenant")
+		all := fs.Bool("all", false, "re-match all done tasks, not only stale ones")
+		pinned := fs.Bool("pinned", false, "only pinned tasks")
+		if err := fs.Parse(args[1:]); err != nil {
+			return errUsage
+		}
+		if *tenant != "" && !config.ValidTenant(*tenant) {
+			return fmt.Errorf("invalid tenant %q", *tenant)
+		}
+		f := vulndb.StaleFilter{Tenant: *tenant, All: *all, Pinned: *pinned}
+		paths := config.NewUploadPaths(cfg.Storage.UploadsDir)
+		policies := policy.NewStore(db, vulns)
+		hooks := webhook.New(db, taskstore.New(db), webhookConfig(cfg))
+		// как у vulnwatch: вердикт пересчитывается до уведомления
+		matched := func(ctx context.Context, t vulndb.TaskRef, res vulndb.MatchResult) {
+			if _, err := policies.Reevaluate(ctx, paths, t); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: re-evaluate policy: %v\n", t.ID, err)
+			}
+			if len(res.Alerts) > 0 {
+				a := vulnwatch.Alert{TaskID: t.ID, Tenant: t.Tenant, SnapshotID: res.SnapshotID,
+					Snapshot: res.Snapshot, Findings: res.Alerts}
+				hooks.NotifyVulns(ctx, a.TaskID, a.Snapshot, a.DedupKey(), a.Findings)
+			}
+		}
+		return rematch(ctx, vulns, paths, f, fs.Args(), matched)
+	}
+	return errUsage
+}
+
+// rematch печатает по строке на задачу и продолжает после ошибок отдельных задач.
+// matched вызывается после сопоставления каждой задачи.
+func rematch(ctx context.Context, vulns *vulndb.Store, paths config.UploadPaths, f vulndb.StaleFilter, ids []string,
+	matched func(context.Context, vulndb.TaskRef, vulndb.MatchResult)) error {
+	var done, failed int
+	one := func(t vulndb.TaskRef) {
+		res, err := vulns.Rematch(ctx, paths, t)
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: %v\n", t.ID, err)
+			return
+		}
+		done++
+		matched(ctx, t, res)
+		fmt.Printf("%s\t%s\t%d vulnerabilities, %d new, %d alerts\n",
+			t.ID, t.Tenant, len(res.Findings), len(res.Added), len(res.Alerts))
+	}
+
+	if len(ids) > 0 {
+		for _, id := range ids {
+			t, err := vulns.DoneTask(ctx, id)
+			if err != nil {
+				failed++
+				if errors.Is(err, sql.ErrNoRows) {
+					err = errors.New("task not found or not done")
+				}
+				fmt.Fprintf(os.Stderr, "%s: %v\n", id, err)
+				continue
+			}
+			if f.Tenant != "" && t.Tenant != f.Tenant {
+				failed++
+				fmt.Fprintf(os.Stderr, "%s: task belongs to tenant %s\n", id, t.Tenant)
+				continue
+			}
+			one(t)
+		}
+	} else {
+		after := ""
+		for {
+			page, err := vulns.StaleTasks(ctx, f, after, 100)
+			if err != nil {
+				return err
+			}
+			for _, t := range page {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				one(t)
+			}
+			if len(page) < 100 {
+				break
+			}
+			after = page[len(page)-1].ID
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "re-matched %d tasks, %d failed\n", done, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d tasks failed", failed)
+	}
+	return nil
+}
+
+func runPolicy(args []string, cfg config.Config) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	fs := flag.NewFlagSet("policy "+args[0], flag.ContinueOnError)
+	tenant := fs.String("tenant", config.DefaultTenant, "tenant of the policy")
+	project := fs.String("project", "", "project; empty = tenant default policy")
+	if err := fs.Parse(args[1:]); err != nil {
+		return errUsage
+	}
+	if !config.ValidTenant(*tenant) {
+		return fmt.Errorf("invalid tenant %q", *tenant)
+	}
+	if args[0] == "reevaluate" {
+		return reevaluate(cfg, *tenant, *project, fs.Args())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	db, err := openDB(ctx, cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	policies := policy.NewStore(db, vulndb.NewStore(db))
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	switch args[0] {
+	case "set":
+		if fs.NArg() != 1 {
+			return errUsage
+		}
+		data, err := os.ReadFile(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		rules, err := policy.Parse(data)
+		if err != nil {
+			return err
+		}
+		if err := policies.Put(ctx, policy.Policy{Tenant: *tenant, Project: *project, Rules: rules}); err != nil {
+			return err
+		}
+		fmt.Println("updated", policyName(*tenant, *project))
+		return nil
+
+	case "show":
+		if fs.NArg() != 0 {
+			return errUsage
+		}
+		p, err := policies.For(ctx, *tenant, *project)
+		if err != nil {
+			return err
+		}
+		if p == nil {
+			return fmt.Errorf("no policy for %s", policyName(*tenant, *project))
+		}
+		return enc.Encode(p)
+
+	case "list":
+		if fs.NArg() != 0 {
+			return errUsage
+		}
+		list, err := policies.List(ctx, *tenant)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(list)
+
+	case "delete":
+		if fs.NArg() != 0 {
+			return errUsage
+		}
+		if err := policies.Delete(ctx, *tenant, *project); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("no policy for %s", policyName(*tenant, *project))
+			}
+			return err
+		}
+		fmt.Println("deleted", policyName(*tenant, *project))
+		return nil
+	}
+	return errUsage
+}
+
+// reevaluate заново проверяет по политике сохранённые SBOM завершённых задач
+// арендатора: после изменения политики вердикты старых задач не пересчитываются.
+func reevaluate(cfg config.Config, tenant, project string, ids []string) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	db, err := openDB(ctx, cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	vulns := vulndb.NewStore(db)
+	policies := policy.NewStore(db, vulns)
+	paths := config.NewUploadPaths(cfg.Storage.UploadsDir)
+
+	var done, failed int
+	one := func(t vulndb.TaskRef) {
+		rep, err := policies.Reevaluate(ctx, paths, t)
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: %v\n", t.ID, err)
+			return
+		}
+		done++
+		verdict := "no policy"
+		if rep != nil {
+			verdict = rep.Verdict
+		}
+		fmt.Printf("%s\t%s\t%s\n", t.ID, t.Tenant, verdict)
+	}
+
+	if len(ids) > 0 {
+		for _, id := range ids {
+			t, err := vulns.DoneTask(ctx, id)
+			if err != nil {
+				failed++
+				if errors.Is(err, sql.ErrNoRows) {
+					err = errors.New("task not found or not done")
+				}
+				fmt.Fprintf(os.Stderr, "%s: %v\n", id, err)
+				continue
+			}
+			if t.Tenant != tenant {
+				failed++
+				fmt.Fprintf(os.Stderr, "%s: task belongs to tenant %s\n", id, t.Tenant)
+				continue
+			}
+			one(t)
+		}
+	} else {
+		after := ""
+		for {
+			page, err := policies.DoneTasks(ctx, tenant, project, after, 100)
+			if err != nil {
+				return err
+			}
+			for _, t := range page {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				one(t)
+			}
+			if len(page) < 100 {
+				break
+			}
+			after = page[len(page)-1].ID
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "re-evaluated %d tasks, %d failed\n", done, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d tasks failed", failed)
+	}
+	return nil
+}
+
+func policyName(tenant, project string) string {
+	if project == "" {
+		return "tenant " + tenant + " (default)"
+	}
+	return "tenant " + tenant + ", project " + project
+}
+
+func runPackages(args []string, cfg config.Config) error {
+	if len(args) == 0 || args[0] != "reindex" {
+		return errUsage
+	}
+	fs := flag.NewFlagSet("packages reindex", flag.ContinueOnError)
+	tenant := fs.String("tenant", "", "only tasks of this tenant")
+	all := fs.Bool("all", false, "re-index all done tasks, not only unindexed ones")
+	if err := fs.Parse(args[1:]); err != nil {
+		return errUsage
+	}
+	if *tenant != "" && !config.ValidTenant(*tenant) {
+		return fmt.Errorf("invalid tenant %q", *tenant)
+	}
+
+	// индексация всех задач занимает минуты: без таймаута
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	db, err := openDB(ctx, cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	packages := pkgindex.NewStore(db)
+	paths := config.NewUploadPaths(cfg.Storage.UploadsDir)
+
+	var done, failed int
+	one := func(t pkgindex.TaskRef) {
+		n, err := packages.Reindex(ctx, paths, t)
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: %v\n", t.ID, err)
+			return
+		}
+		done++
+		fmt.Printf("%s\t%s\t%d packages\n", t.ID, t.Tenant, n)
+	}
+
+	if ids := fs.Args(); len(ids) > 0 {
+		for _, id := range ids {
+			t, err := packages.DoneTask(ctx, id)
+			if err != nil {
+				failed++
+				if errors.Is(err, sql.ErrNoRows) {
+					err = errors.New("task not found or not done")
+				}
+				fmt.Fprintf(os.Stderr, "%s: %v\n", id, err)
+				continue
+			}
+			if *tenant != "" && t.Tenant != *tenant {
+				failed++
+				fmt.Fprintf(os.Stderr, "%s: task belongs to tenant %s\n", id, t.Tenant)
+				continue
+			}
+			one(t)
+		}
+	} else {
+		after := ""
+		for {
+			page, err := packages.Unindexed(ctx, *tenant, *all, after, 100)
+			if err != nil {
+				return err
+			}
+			for _, t := range page {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				one(t)
+			}
+			if len(page) < 100 {
+				break
+			}
+			after = page[len(page)-1].ID
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "indexed %d tasks, %d failed\n", done, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d tasks failed", failed)
+	}
+	return nil
+}
+
+func runProjects(args []string, cfg config.Config) error {
+	if len(args) == 0 || args[0] != "reindex" {
+		return errUsage
+	}
+	fs := flag.NewFlagSet("projects reindex", flag.ContinueOnError)
+	tenant := fs.String("tenant", "", "only tasks of this tenant")
+	if err := fs.Parse(args[1:]); err != nil {
+		return errUsage
+	}
+	if *tenant != "" && !config.ValidTenant(*tenant) {
+		return fmt.Errorf("invalid tenant %q", *tenant)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	db, err := openDB(ctx, cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	projects := project.NewStore(db)
+
+	var done, failed int
+	one := func(t project.TaskRef) {
+		if err := projects.Record(ctx, t.ID); err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: %v\n", t.ID, err)
+			return
+		}
+		done++
+		fmt.Printf("%s\t%s\t%s\t%s\n", t.ID, t.Tenant, t.Project, t.Version)
+	}
+
+	if ids := fs.Args(); len(ids) > 0 {
+		for _, id := range ids {
+			t, err := projects.DoneTask(ctx, id)
+			if err != nil {
+				failed++
+				if errors.Is(err, sql.ErrNoRows) {
+					err = errors.New("task not found, not done or has no project")
+				}
+				fmt.Fprintf(os.Stderr, "%s: %v\n", id, err)
+				continue
+			}
+			if *tenant != "" && t.Tenant != *tenant {
+				failed++
+				fmt.Fprintf(os.Stderr, "%s: task belongs to tenant %s\n", id, t.Tenant)
+				continue
+			}
+			one(t)
+		}
+	} else {
+		after := ""
+		for {
+			page, err := projects.Unrecorded(ctx, *tenant, after, 100)
+			if err != nil {
+				return err
+			}
+			for _, t := range page {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				one(t)
+			}
+			if len(page) < 100 {
+				break
+			}
+			after = page[len(page)-1].ID
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "recorded %d tasks, %d failed\n", done, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d tasks failed", failed)
+	}
+	return nil
+}
@@ -2,25 +2,73 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"database/sql"
-	"log"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
-	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 	httpSwagger "github.com/swaggo/http-swagger/v2"
 
+	"sbom-serv/internal/auth"
+	"sbom-serv/internal/certs"
 	"sbom-serv/internal/config"
+	"sbom-serv/internal/events"
+	"sbom-serv/internal/health"
 	"sbom-serv/internal/httpapi"
 	"sbom-serv/internal/janitor"
+	"sbom-serv/internal/logging"
+	"sbom-serv/internal/metrics"
+	"sbom-serv/internal/pkgindex"
+	"sbom-serv/internal/policy"
+	"sbom-serv/internal/project"
+	"sbom-serv/internal/sbom"
 	"sbom-serv/internal/taskstore"
+	"sbom-serv/internal/tracing"
+	"sbom-serv/internal/vulndb"
+	"sbom-serv/internal/vulnwatch"
+	"sbom-serv/internal/webhook"
 	"sbom-serv/internal/worker"
 )
 
 func main() {
+	fs := flag.NewFlagSet("sbom-serv", flag.ExitOnError)
+	configFile := fs.String("config", os.Getenv("SBOM_CONFIG"), "YAML config file (env SBOM_CONFIG)")
+	printConfig := fs.Bool("print-config", false, "print effective configuration and exit")
+	overrides := config.RegisterFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+	}
+	_ = fs.Parse(os.Args[1:])
+
+	cfg, err := config.Load(*configFile, overrides)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(2)
+	}
+	if *printConfig {
+		out, err := cfg.Redacted().YAML()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(out)
+		return
+	}
+	if err := logging.Setup(os.Stderr, cfg.Log.Format, cfg.Log.Level); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(2)
+	}
+	if fs.NArg() > 0 {
+		os.Exit(runCommand(fs.Args(), cfg))
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -31,96 +79,323 @@ func main() {
 		cancel()
 	}()
 
-	dsn := os.Getenv("DATABASE_URL")
-
-	db, err := sql.Open("pgx", dsn)
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		File:        cfg.Tracing.File,
+		SampleRatio: cfg.Tracing.SampleRatio,
+		ServiceName: cfg.Tracing.ServiceName,
+	})
 	if err != nil {
-		log.Fatal(err)
-	}
-	if err2 := db.Ping(); err2 != nil {
-		log.Fatal(err2)
+		fatal("tracing", err)
 	}
+	defer func() {
+		// контекст уже отменён сигналом: отправляем оставшиеся спаны с отдельным таймаутом
+		flushCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.D())
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			slog.Error("flush traces", "err", err)
+		}
+	}()
 
-	defer db.Close()
-
-	db.SetMaxOpenConns(20)
-	db.SetMaxIdleConns(20)
-	db.SetConnMaxLifetime(30 * time.Minute)
-
-	if err := db.PingContext(ctx); err != nil {
-		log.Fatal(err)
+	db, err := openDB(ctx, cfg.DB)
+	if err != nil {
+		fatal("open database", err)
 	}
+	defer db.Close()
 
 	store := taskstore.New(db)
+	keys := auth.NewKeyStore(db)
 
-	paths := config.NewUploadPaths("./uploads")
+	paths := config.NewUploadPaths(cfg.Storage.UploadsDir)
 	if err := paths.Ensure(); err != nil {
-		log.Fatal(err)
+		fatal("prepare uploads dir", err)
 	}
 
-	cfg := janitor.DefaultConfig()
-	cfg.Retention = 24 * time.Hour     // интервал удаления задач
-	cfg.RunningTimeout = 3 * time.Hour // умершие задачи
-	cfg.RunningTimeoutAction = janitor.RunningFail
-	cfg.Every = 1 * time.Hour
+	hub := events.NewHub(db)
+	go hub.Run(ctx)
+
+	hooks := webhook.New(db, store, webhookConfig(cfg))
+	go hooks.Start(ctx)
 
-	j := janitor.New(db, paths, cfg)
+	j := janitor.New(db, paths, janitor.Config{
+		Every:                cfg.Janitor.Every.D(),
+		Retention:            cfg.Janitor.Retention.D(),
+		RunningTimeout:       cfg.Janitor.RunningTimeout.D(),
+		RunningTimeoutAction: janitor.RunningAction(cfg.Janitor.RunningTimeoutAction),
+		TmpMaxAge:            cfg.Janitor.TmpMaxAge.D(),
+		BatchSize:            cfg.Janitor.BatchSize,
+		AdvisoryLockKey:      cfg.Janitor.AdvisoryLockKey,
+	})
+	j.OnTaskFailed(hooks.Notify)
 	go j.Start(ctx)
-	go worker.StartWorker(ctx, store, paths, 5) // тут поменять количетсво потоков
+
+	w := worker.New(store, paths, worker.Config{
+		Parallel:         cfg.Worker.Parallel,
+		PollEvery:        cfg.Worker.PollEvery.D(),
+		SyftPath:         cfg.Worker.SyftPath,
+		ScanTimeout:      cfg.Worker.ScanTimeout.D(),
+		MaxLogBytes:      cfg.Worker.MaxLogBytes,
+		MaxUnpackedBytes: cfg.Limits.MaxUnpackedBytes,
+		MaxFiles:         cfg.Limits.MaxArchiveFiles,
+	})
+	vulns := vulndb.NewStore(db)
+	if cfg.Vulns.Enabled {
+		w.Enrich(taskstore.StageMatching, func(ctx context.Context, id string, doc *sbom.Document) error {
+			_, err := vulns.MatchTask(ctx, id, doc)
+			return err
+		})
+	}
+	policies := policy.NewStore(db, vulns)
+	w.Analyze(taskstore.StagePolicy, func(ctx context.Context, id string, doc *sbom.Document) error {
+		_, err := policies.EvaluateTask(ctx, id, doc)
+		return err
+	})
+	packages := pkgindex.NewStore(db)
+	w.Enrich(taskstore.StageIndexing, packages.Index)
+	projects := project.NewStore(db)
+	w.OnFinish(projects.TaskFinished)
+	w.OnFinish(hooks.Notify)
+	go w.Start(ctx)
+
+	watcher := vulnwatch.New(db, vulns, paths, vulnwatch.Config{
+		Every:           cfg.Vulns.WatchEvery.D(),
+		AdvisoryLockKey: cfg.Vulns.WatchAdvisoryLockKey,
+	})
+	// вердикт зависит от находок: проверка повторяется до уведомления task.vulnerabilities
+	watcher.OnRematch(func(ctx context.Context, t vulndb.TaskRef) {
+		if _, err := policies.Reevaluate(ctx, paths, t); err != nil {
+			slog.Error("re-evaluate policy", "task_id", t.ID, "tenant", t.Tenant, "err", err)
+		}
+	})
+	watcher.OnAlert(func(ctx context.Context, a vulnwatch.Alert) {
+		hooks.NotifyVulns(ctx, a.TaskID, a.Snapshot, a.DedupKey(), a.Findings)
+	})
+	if cfg.Vulns.Enabled {
+		go watcher.Start(ctx)
+	}
+
+	metrics.RegisterQueue(store)
+	metrics.RegisterWorkerSlots(w.Busy, w.Capacity)
+
+	checker := health.New(db, paths, health.Config{
+		MinFreeBytes:      cfg.Health.MinFreeBytes,
+		SyftPath:          cfg.Worker.SyftPath,
+		ScannerCheckEvery: cfg.Health.ScannerCheckEvery.D(),
+		Timeout:           cfg.Health.Timeout.D(),
+	})
 
 	mux := http.NewServeMux()
-	mux.Handle("/scan", httpapi.UploadZipHandler(paths, store))
-	mux.Handle("/scan/info", httpapi.ScanInfoHandler(paths, store))
+	// права на каждый маршрут объявлены в httpapi.RoutePermissions
+	handle := func(pattern string, h http.Handler) {
+		mux.Handle(pattern, httpapi.Authorize(pattern, h))
+	}
+
+	handle("POST /scan", httpapi.UploadZipHandler(paths, store, hooks, cfg.Limits.MaxUploadBytes))
+	handle("GET /scans", httpapi.ScanListHandler(store))
+	handle("GET /scan/info", httpapi.ScanInfoHandler(paths, store, hub, policies))
+	handle("GET /scan/{id}/logs", httpapi.ScanLogsHandler(paths, store))
+	handle("GET /scan/{id}/events", httpapi.ScanEventsHandler(store, hub))
+	handle("GET /scans/events", httpapi.ProjectEventsHandler(store, hub))
+	handle("GET /scans/diff", httpapi.ScanDiffHandler(paths, store, vulns))
+	handle("GET /packages/search", httpapi.PackageSearchHandler(packages))
+	handle("GET /projects", httpapi.ProjectListHandler(projects))
+	handle("GET /projects/{project}/versions", httpapi.ProjectVersionsHandler(projects))
+	handle("GET /projects/{project}/sbom", httpapi.ProjectSBOMHandler(paths, projects))
+	handle("GET /scan/{id}/webhooks", httpapi.WebhookDeliveriesHandler(store, hooks))
+	handle("POST /scan/{id}/webhooks/redeliver", httpapi.WebhookRedeliverHandler(store, hooks))
+	handle("GET /scan/{id}/vulnerabilities", httpapi.ScanVulnsHandler(store, vulns))
+	handle("GET /scan/{id}/policy", httpapi.ScanPolicyHandler(store, policies))
+	handle("GET /vulns/alerts", httpapi.VulnAlertsHandler(watcher))
+	handle("POST /scan/{id}/pin", httpapi.PinScanHandler(store, true))
+	handle("DELETE /scan/{id}/pin", httpapi.PinScanHandler(store, false))
+	handle("POST /scan/{id}/cancel", httpapi.CancelScanHandler(store, hooks.Notify))
+	handle("DELETE /scan/{id}", httpapi.DeleteScanHandler(paths, store))
+
+	handle("GET /healthz", httpapi.HealthzHandler())
+	handle("GET /readyz", httpapi.ReadyzHandler(checker))
+	handle("GET /status", httpapi.StatusHandler(checker, store, w))
+	handle("GET /metrics", metrics.Handler())
 
-	mux.HandleFunc("/openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+	handle("POST /admin/janitor/run", httpapi.JanitorRunHandler(j))
+	handle("GET /admin/keys", httpapi.ListKeysHandler(keys))
+	handle("POST /admin/keys", httpapi.CreateKeyHandler(keys))
+	handle("DELETE /admin/keys/{id}", httpapi.RevokeKeyHandler(keys))
+
+	handle("GET /openapi.yaml", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
-		http.ServeFile(w, r, "./docs/openapi.yaml")
-	})
+		http.ServeFile(w, r, cfg.Server.OpenAPIFile)
+	}))
 
-	mux.Handle("/swagger/", httpSwagger.Handler(
-		
+	handle("GET /swagger/", httpSwagger.Handler(
 		httpSwagger.URL("/openapi.yaml"),
 	))
+
+	tlsCfg, err := serverTLSConfig(ctx, cfg.TLS)
+	if err != nil {
+		fatal("tls config", err)
+	}
+
+	// снаружи внутрь: request id, трассировка, метрики, CORS, аутентификация
+	route := routePattern(mux)
+	var handler http.Handler = auth.Middleware(mux, authenticator(ctx, cfg.Auth, cfg.TLS, keys), isPublicPath)
+	handler = httpapi.CORS(handler, httpapi.CORSPolicy{
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
+		AllowedMethods:   cfg.CORS.AllowedMethods,
+		AllowedHeaders:   cfg.CORS.AllowedHeaders,
+		ExposedHeaders:   cfg.CORS.ExposedHeaders,
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		MaxAge:           cfg.CORS.MaxAge.D(),
+	})
+	handler = metrics.Middleware(handler, route)
+	handler = tracing.Middleware(handler, route)
+	handler = logging.Middleware(handler, route)
+
 	srv := &http.Server{
-		Addr:              ":8082",
-		Handler:           corsMiddleware(mux),
-		ReadHeaderTimeout: 10 * time.Second,
+		Addr:              cfg.Server.Addr,
+		Handler:           handler,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+		TLSConfig:         tlsCfg,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout.D(),
 	}
 
 	go func() {
 		<-ctx.Done()
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.D())
 		defer cancel()
 		_ = srv.Shutdown(shutdownCtx)
 	}()
 
-	certFile := "/app/certs/fullchain.pem"
-	keyFile := "/app/certs/tvles-dintp0005.esrt.sber.ru.key"
-	//certFile := "/app/certs/server-cert.pem"
-	//keyFile := "/app/certs/SBOM_GEN.key"
-	// instead ListenAndServe there was ListenAndServeTLS(certFile, keyFile) used before
-	log.Println("listening on", srv.Addr)
-	if err := srv.ListenAndServeTLS(certFile, keyFile); err != nil && err != http.ErrServerClosed {
-		log.Fatal(err)
+	if tlsCfg == nil {
+		slog.Warn("TLS is disabled, serving plain HTTP")
+		slog.Info("listening", "addr", srv.Addr, "tls", false)
+		err = srv.ListenAndServe()
+	} else {
+		slog.Info("listening", "addr", srv.Addr, "tls", true)
+		// сертификат берётся из tlsCfg.GetCertificate
+		err = srv.ListenAndServeTLS("", "")
+	}
+	if err != nil && err != http.ErrServerClosed {
+		fatal("serve", err)
 	}
 }
 
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
+}
+
+func openDB(ctx context.Context, cfg config.DBConfig) (*sql.DB, error) {
+	db, err := sql.Open("pgx", cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime.D())
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
 
+// webhookConfig — общая для сервера и команд: команды только ставят
+// доставки в очередь, отправляет их сервер.
+func webhookConfig(cfg config.Config) webhook.Config {
+	return webhook.Config{
+		Every:        cfg.Webhook.Every.D(),
+		MaxAttempts:  cfg.Webhook.MaxAttempts,
+		BaseBackoff:  cfg.Webhook.BaseBackoff.D(),
+		MaxBackoff:   cfg.Webhook.MaxBackoff.D(),
+		Timeout:      cfg.Webhook.Timeout.D(),
+		BatchSize:    cfg.Webhook.BatchSize,
+		AllowedHosts: cfg.Webhook.AllowedHosts,
+	}
+}
 
+// authenticator возвращает nil, если аутентификация выключена (auth.disabled).
+// JWT включается, если задан auth.jwt.jwks_file или auth.jwt.jwks_url.
+func authenticator(ctx context.Context, cfg config.AuthConfig, tlsCfg config.TLSConfig, keys *auth.KeyStore) auth.Authenticator {
+	if cfg.Disabled {
+		slog.Warn("authentication is disabled")
+		return nil
+	}
+	// явный bearer-токен важнее сертификата: через один mTLS-клиент могут ходить разные пользователи
+	chain := auth.Chain{auth.BearerKeys{Keys: keys}}
 
+	if cfg.JWT.Enabled() {
+		jcfg := auth.DefaultJWTConfig()
+		jcfg.Issuer = cfg.JWT.Issuer
+		jcfg.Audience = cfg.JWT.Audience
+		jcfg.JWKSFile = cfg.JWT.JWKSFile
+		jcfg.JWKSURL = cfg.JWT.JWKSURL
+		jcfg.CacheFile = cfg.JWT.CacheFile
+		jcfg.Refresh = cfg.JWT.Refresh.D()
+		if cfg.JWT.TenantClaim != "" {
+			jcfg.TenantClaim = cfg.JWT.TenantClaim
+		}
+		if cfg.JWT.RolesClaim != "" {
+			jcfg.RolesClaim = cfg.JWT.RolesClaim
+		}
+		jv, err := auth.NewJWTValidator(jcfg)
+		if err != nil {
+			fatal("jwt validator", err)
+		}
+		go jv.Start(ctx)
+		chain = append(chain, jv)
+	}
+	if tlsCfg.ClientCA != "" {
+		chain = append(chain, auth.ClientCert{})
+	}
+	return chain
+}
 
+// serverTLSConfig возвращает nil, если TLS выключен (tls.enabled=false).
+// Сертификат перечитывается с диска при изменении файлов без перезапуска.
+// tls.client_ca включает проверку клиентских сертификатов (mTLS).
+func serverTLSConfig(ctx context.Context, cfg config.TLSConfig) (*tls.Config, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	reloader, err := certs.NewReloader(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	go reloader.Start(ctx, cfg.ReloadEvery.D())
 
-func corsMiddleware(next http.Handler) http.Handler {
-    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        w.Header().Set("Access-Control-Allow-Origin", "*")
-        w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
-        w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	tlsCfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if cfg.ClientCA == "" {
+		return tlsCfg, nil
+	}
+	clientAuth, err := auth.ParseClientAuth(cfg.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := auth.LoadCertPool(cfg.ClientCA)
+	if err != nil {
+		return nil, err
+	}
+	tlsCfg.ClientCAs = pool
+	tlsCfg.ClientAuth = clientAuth
+	return tlsCfg, nil
+}
 
-        if r.Method == http.MethodOptions {
-            w.WriteHeader(http.StatusNoContent)
-            return
-        }
+// routePattern — шаблон маршрута для метрик, логов и спанов; запросы, которые mux отверг
+// (404, 405), идут под пустым шаблоном.
+func routePattern(mux *http.ServeMux) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		_, pattern := mux.Handler(r)
+		return pattern
+	}
+}
 
-        next.ServeHTTP(w, r)
-    })
+func isPublicPath(path string) bool {
+	return path == "/healthz" || path == "/readyz" ||
+		path == "/openapi.yaml" || strings.HasPrefix(path, "/swagger/")
 }
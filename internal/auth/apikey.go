@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"sbom-serv/internal/config"
+)
+
+// Префикс ключей сервиса: по нему ключ отличается от других bearer-токенов
+const KeyPrefix = "sbk_"
+
+var ErrInvalidKey = errors.New("invalid api key")
+
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Tenant     string     `json:"tenant"`
+	Roles      []string   `json:"roles"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// KeyStore хранит API-ключи в sbom_api_keys. В БД лежит только SHA-256 ключа,
+// сам ключ показывается один раз при создании.
+type KeyStore struct {
+	db *sql.DB
+}
+
+func NewKeyStore(db *sql.DB) *KeyStore { return &KeyStore{db: db} }
+
+func (s *KeyStore) Create(ctx context.Context, name, tenant string, roles []string) (APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return APIKey{}, "", errors.New("key name is required")
+	}
+	if tenant == "" {
+		tenant = config.DefaultTenant
+	}
+	if !config.ValidTenant(tenant) {
+		return APIKey{}, "", fmt.Errorf("invalid tenant %q", tenant)
+	}
+	if len(roles) == 0 {
+		roles = DefaultKeyRoles
+	}
+	for _, r := range roles {
+		if !ValidRole(r) {
+			return APIKey{}, "", fmt.Errorf("unknown role %q", r)
+		}
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return APIKey{}, "", err
+	}
+	secret := KeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	k := APIKey{
+		ID:     uuid.NewString(),
+		Name:   name,
+		Prefix: secret[:len(KeyPrefix)+6],
+		Tenant: tenant,
+		Roles:  roles,
+	}
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO sbom_api_keys(id, name, prefix, key_hash, tenant_id, roles)
+		VALUES ($1, $2, $3, $4, $5, string_to_array($6, ','))
+		RETURNING created_at
+	`, k.ID, k.Name, k.Prefix, hashKey(secret), k.Tenant, strings.Join(roles, ",")).Scan(&k.CreatedAt)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	return k, secret, nil
+}
+
+// Revoke отзывает ключ арендатора; пустой tenant — ключ любого арендатора (CLI).
+func (s *KeyStore) Revoke(ctx context.Context, tenant, id string) error {
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE sbom_api_keys
+		SET revoked_at = now()
+		WHERE id = $1 AND revoked_at IS NULL
+		  AND ($2 = '' OR tenant_id = $2)
+	`, id, tenant)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// List возвращает ключи арендатора; пустой tenant — всех арендаторов (CLI).
+func (s *KeyStore) List(ctx context.Context, tenant string) ([]APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id::text, name, prefix, tenant_id, array_to_string(roles, ','),
+		       created_at, last_used_at, revoked_at
+		FROM sbom_api_keys
+		WHERE $1 = '' OR tenant_id = $1
+		ORDER BY created_at ASC
+	`, tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		var k APIKey
+		var used, revoked sql.NullTime
+		var roles string
+		if err := rows.Scan(&k.ID, &k.Name, &k.Prefix, &k.Tenant, &roles, &k.CreatedAt, &used, &revoked); err != nil {
+			return nil, err
+		}
+		k.Roles = splitRoles(roles)
+		if used.Valid {
+			k.LastUsedAt = &used.Time
+		}
+		if revoked.Valid {
+			k.RevokedAt = &revoked.Time
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// Lookup проверяет ключ и возвращает личность его владельца. last_used_at
+// обновляется не чаще раза в минуту, чтобы не писать в БД на каждый запрос.
+func (s *KeyStore) Lookup(ctx context.Context, secret string) (Identity, error) {
+	if !strings.HasPrefix(secret, KeyPrefix) {
+		return Identity{}, ErrInvalidKey
+	}
+
+	var id, name, tenant, roles string
+	err := s.db.QueryRowContext(ctx, `
+		WITH k AS (
+			SELECT id, name, tenant_id, roles, last_used_at
+			FROM sbom_api_keys
+			WHERE key_hash = $1 AND revoked_at IS NULL
+		), touched AS (
+			UPDATE sbom_api_keys
+			SET last_used_at = now()
+			WHERE id IN (
+				SELECT id FROM k
+				WHERE last_used_at IS NULL OR last_used_at < now() - interval '1 minute'
+			)
+		)
+		SELECT id::text, name, tenant_id, array_to_string(roles, ',') FROM k
+	`, hashKey(secret)).Scan(&id, &name, &tenant, &roles)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Identity{}, ErrInvalidKey
+	}
+	if err != nil {
+		return Identity{}, err
+	}
+	return Identity{
+		ClientID: "key:" + id,
+		Name:     name,
+		Method:   "apikey",
+		Tenant:   tenant,
+		Roles:    splitRoles(roles),
+	}, nil
+}
+
+func splitRoles(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
+
+func hashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"context"
+
+	"sbom-serv/internal/config"
+)
+
+// Identity — кто выполняет запрос. ClientID записывается в задачу
+// и используется для списков, квот и аудита.
+type Identity struct {
+	ClientID string
+	Name     string
+	// Способ аутентификации: apikey, jwt, anonymous
+	Method string
+	Tenant string
+	Roles  []string
+}
+
+// Anonymous используется, когда аутентификация выключена: проверять права некому,
+// поэтому у него есть все роли.
+var Anonymous = Identity{
+	ClientID: "anonymous",
+	Name:     "anonymous",
+	Method:   "anonymous",
+	Tenant:   config.DefaultTenant,
+	Roles:    []string{RoleAdmin},
+}
+
+type ctxKey struct{}
+
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(ctxKey{}).(Identity)
+	return id, ok
+}
+
+// TenantOf — арендатор запроса. Без личности в контексте — арендатор по умолчанию.
+func TenantOf(ctx context.Context) string {
+	if id, ok := FromContext(ctx); ok && id.Tenant != "" {
+		return id.Tenant
+	}
+	return config.DefaultTenant
+}
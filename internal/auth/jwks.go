@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// KeySet — публичные ключи из JWKS по kid.
+type KeySet struct {
+	keys map[string]any
+	// ключи без kid: используются, если в токене kid не указан
+	anon []any
+	// ключи, которые не удалось разобрать (неизвестная кривая, экспонента)
+	skipped []error
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJWKS разбирает документ JWKS (RFC 7517). Ключи неизвестных типов
+// и ключи шифрования пропускаются, неподдерживаемые ключи — тоже (см. Skipped):
+// IdP публикует в одном наборе ключи разных видов. Ошибка — только если
+// подходящих ключей не осталось.
+func ParseJWKS(data []byte) (*KeySet, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("decode jwks: %w", err)
+	}
+
+	ks := &KeySet{keys: make(map[string]any)}
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			ks.skipped = append(ks.skipped, fmt.Errorf("jwks key %q: %w", k.Kid, err))
+			continue
+		}
+		if pub == nil {
+			continue
+		}
+		if k.Kid == "" {
+			ks.anon = append(ks.anon, pub)
+			continue
+		}
+		ks.keys[k.Kid] = pub
+	}
+	if len(ks.keys) == 0 && len(ks.anon) == 0 {
+		return nil, errors.Join(append([]error{errors.New("jwks contains no usable signing keys")}, ks.skipped...)...)
+	}
+	return ks, nil
+}
+
+// Skipped — ошибки разбора пропущенных ключей.
+func (ks *KeySet) Skipped() []error {
+	return ks.skipped
+}
+
+func (ks *KeySet) Len() int {
+	return len(ks.keys) + len(ks.anon)
+}
+
+// Key ищет ключ по kid. Без kid подходит только единственный ключ в наборе.
+func (ks *KeySet) Key(kid string) (any, bool) {
+	if kid != "" {
+		k, ok := ks.keys[kid]
+		return k, ok
+	}
+	if ks.Len() != 1 {
+		return nil, false
+	}
+	for _, k := range ks.keys {
+		return k, true
+	}
+	return ks.anon[0], true
+}
+
+func (k jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := b64Int(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("n: %w", err)
+		}
+		e, err := b64Int(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("e: %w", err)
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("unsupported rsa exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := b64Int(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("x: %w", err)
+		}
+		y, err := b64Int(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("y: %w", err)
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		if _, err := pub.ECDH(); err != nil {
+			return nil, fmt.Errorf("invalid ec key: %w", err)
+		}
+		return pub, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("x: %w", err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, nil
+}
+
+func b64Int(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, errors.New("missing value")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"sbom-serv/internal/logging"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+type JWTConfig struct {
+	// Ожидаемые iss и aud (aud может быть одним из нескольких в токене)
+	Issuer   string
+	Audience string
+
+	// Источник ключей: локальный файл JWKS или URL IdP.
+	// Для URL последний успешно загруженный набор сохраняется в CacheFile,
+	// чтобы сервис мог стартовать без доступа к IdP.
+	JWKSFile  string
+	JWKSURL   string
+	CacheFile string
+
+	// Как часто перечитывать JWKS (файл или URL)
+	Refresh time.Duration
+
+	// Допуск на расхождение часов при проверке exp/nbf/iat
+	Leeway time.Duration
+
+	// Имена claim-ов; поддерживается путь через точку: realm_access.roles
+	SubjectClaim string
+	TenantClaim  string
+	RolesClaim   string
+}
+
+func DefaultJWTConfig() JWTConfig {
+	return JWTConfig{
+		Refresh:      10 * time.Minute,
+		Leeway:       30 * time.Second,
+		SubjectClaim: "sub",
+		TenantClaim:  "tenant",
+		RolesClaim:   "roles",
+	}
+}
+
+// JWTValidator проверяет bearer-токены IdP: подпись по JWKS, iss, aud, exp, nbf.
+type JWTValidator struct {
+	cfg    JWTConfig
+	keys   atomic.Pointer[KeySet]
+	client *http.Client
+	parser *jwt.Parser
+	log    *slog.Logger
+}
+
+func NewJWTValidator(cfg JWTConfig) (*JWTValidator, error) {
+	def := DefaultJWTConfig()
+	if cfg.Refresh <= 0 {
+		cfg.Refresh = def.Refresh
+	}
+	if cfg.SubjectClaim == "" {
+		cfg.SubjectClaim = def.SubjectClaim
+	}
+	if cfg.TenantClaim == "" {
+		cfg.TenantClaim = def.TenantClaim
+	}
+	if cfg.RolesClaim == "" {
+		cfg.RolesClaim = def.RolesClaim
+	}
+	if cfg.JWKSFile == "" && cfg.JWKSURL == "" {
+		return nil, errors.New("jwt: JWKS file or URL is required")
+	}
+	if cfg.Issuer == "" || cfg.Audience == "" {
+		return nil, errors.New("jwt: issuer and audience are required")
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(cfg.Issuer),
+		jwt.WithAudience(cfg.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(cfg.Leeway),
+	}
+	v := &JWTValidator{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		parser: jwt.NewParser(opts...),
+		log:    logging.Component("auth"),
+	}
+
+	if err := v.reload(context.Background()); err != nil {
+		// при недоступном IdP стартуем с закэшированным набором
+		if cfg.JWKSURL == "" || cfg.CacheFile == "" {
+			return nil, err
+		}
+		data, cacheErr := os.ReadFile(cfg.CacheFile)
+		if cacheErr != nil {
+			return nil, fmt.Errorf("%w (no cached jwks: %v)", err, cacheErr)
+		}
+		ks, cacheErr := v.parse(data)
+		if cacheErr != nil {
+			return nil, fmt.Errorf("%w (bad cached jwks: %v)", err, cacheErr)
+		}
+		v.keys.Store(ks)
+		v.log.Warn("jwks fetch failed, using cache", "cache_file", cfg.CacheFile, "err", err)
+	}
+	return v, nil
+}
+
+// Start периодически перечитывает JWKS. Ошибка обновления не сбрасывает
+// уже загруженные ключи.
+func (v *JWTValidator) Start(ctx context.Context) {
+	ticker := time.NewTicker(v.cfg.Refresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := v.reload(ctx); err != nil {
+				v.log.Error("jwks refresh", "err", err)
+			}
+		}
+	}
+}
+
+func (v *JWTValidator) reload(ctx context.Context) error {
+	var data []byte
+	var err error
+	if v.cfg.JWKSFile != "" {
+		data, err = os.ReadFile(v.cfg.JWKSFile)
+	} else {
+		data, err = v.fetch(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	ks, err := v.parse(data)
+	if err != nil {
+		return err
+	}
+	v.keys.Store(ks)
+
+	if v.cfg.JWKSURL != "" && v.cfg.CacheFile != "" {
+		if err := writeFileAtomic(v.cfg.CacheFile, data); err != nil {
+			v.log.Warn("write jwks cache", "cache_file", v.cfg.CacheFile, "err", err)
+		}
+	}
+	return nil
+}
+
+// parse разбирает JWKS и пишет в лог пропущенные ключи.
+func (v *JWTValidator) parse(data []byte) (*KeySet, error) {
+	ks, err := ParseJWKS(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, err := range ks.Skipped() {
+		v.log.Warn("jwks key skipped", "err", err)
+	}
+	return ks, nil
+}
+
+func (v *JWTValidator) fetch(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.cfg.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks: unexpected status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func (v *JWTValidator) Authenticate(r *http.Request) (Identity, error) {
+	token, ok := BearerToken(r)
+	// API-ключи обрабатывает BearerKeys
+	if !ok || strings.HasPrefix(token, KeyPrefix) {
+		return Identity{}, ErrNoCredentials
+	}
+	return v.Validate(token)
+}
+
+// Validate проверяет токен и переводит его claims в Identity.
+func (v *JWTValidator) Validate(token string) (Identity, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		ks := v.keys.Load()
+		if ks == nil {
+			return nil, errors.New("no signing keys loaded")
+		}
+		kid, _ := t.Header["kid"].(string)
+		key, ok := ks.Key(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		return key, nil
+	})
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	sub, _ := lookupClaim(claims, v.cfg.SubjectClaim).(string)
+	if sub == "" {
+		return Identity{}, fmt.Errorf("%w: missing %s claim", ErrInvalidToken, v.cfg.SubjectClaim)
+	}
+	id := Identity{
+		ClientID: "jwt:" + sub,
+		Name:     sub,
+		Method:   "jwt",
+		Roles:    claimStrings(lookupClaim(claims, v.cfg.RolesClaim)),
+	}
+	if tenant, ok := lookupClaim(claims, v.cfg.TenantClaim).(string); ok {
+		id.Tenant = tenant
+	}
+	for _, c := range []string{"preferred_username", "name", "email"} {
+		if s, ok := claims[c].(string); ok && s != "" {
+			id.Name = s
+			break
+		}
+	}
+	return id, nil
+}
+
+func lookupClaim(claims jwt.MapClaims, path string) any {
+	var cur any = map[string]any(claims)
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur = m[part]
+	}
+	return cur
+}
+
+// claimStrings принимает массив строк или строку через пробел (как scope).
+func claimStrings(v any) []string {
+	switch x := v.(type) {
+	case string:
+		return strings.Fields(x)
+	case []any:
+		out := make([]string, 0, len(x))
+		for _, it := range x {
+			if s, ok := it.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func ecJWK(t *testing.T, kid string, pub *ecdsa.PublicKey) map[string]string {
+	t.Helper()
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	return map[string]string{
+		"kty": "EC", "kid": kid, "use": "sig", "crv": "P-256",
+		"x": b64(pub.X.FillBytes(make([]byte, 32))),
+		"y": b64(pub.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func jwksJSON(t *testing.T, keys ...map[string]string) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// набор IdP с ключами, которые сервис не поддерживает
+var unsupportedKeys = []map[string]string{
+	{"kty": "EC", "kid": "p192", "use": "sig", "crv": "P-192", "x": "AQ", "y": "AQ"},
+	{"kty": "RSA", "kid": "rsa-e1", "use": "sig", "n": "AQAB", "e": "AQ"},
+	{"kty": "OKP", "kid": "x448", "crv": "X448", "x": "AQ"},
+	{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+	{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+}
+
+func TestParseJWKSSkipsUnsupportedKeys(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks, err := ParseJWKS(jwksJSON(t, append(unsupportedKeys, ecJWK(t, "good", &key.PublicKey))...))
+	if err != nil {
+		t.Fatalf("ParseJWKS: %v", err)
+	}
+	if ks.Len() != 1 {
+		t.Errorf("Len = %d, want 1", ks.Len())
+	}
+	if _, ok := ks.Key("good"); !ok {
+		t.Error("key good not found")
+	}
+	if got := len(ks.Skipped()); got != 3 {
+		t.Errorf("Skipped = %v, want 3 errors", ks.Skipped())
+	}
+
+	if _, err := ParseJWKS(jwksJSON(t, unsupportedKeys...)); err == nil {
+		t.Error("ParseJWKS without usable keys: want error")
+	}
+}
+
+func TestJWTValidate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(file, jwksJSON(t, append(unsupportedKeys, ecJWK(t, "k1", &key.PublicKey))...), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := NewJWTValidator(JWTConfig{
+		Issuer:   "https://idp.example.com",
+		Audience: "sbom-serv",
+		JWKSFile: file,
+	})
+	if err != nil {
+		t.Fatalf("NewJWTValidator: %v", err)
+	}
+
+	now := time.Now()
+	claims := func(mod func(jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss":    "https://idp.example.com",
+			"aud":    []string{"other", "sbom-serv"},
+			"sub":    "ci-bot",
+			"tenant": "team-a",
+			"roles":  []string{"uploader", "reader"},
+			"iat":    now.Unix(),
+			"exp":    now.Add(time.Hour).Unix(),
+		}
+		if mod != nil {
+			mod(c)
+		}
+		return c
+	}
+	sign := func(method jwt.SigningMethod, signKey any, kid string, c jwt.MapClaims) string {
+		tok := jwt.NewWithClaims(method, c)
+		if kid != "" {
+			tok.Header["kid"] = kid
+		}
+		s, err := tok.SignedString(signKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	id, err := v.Validate(sign(jwt.SigningMethodES256, key, "k1", claims(nil)))
+	if err != nil {
+		t.Fatalf("valid token: %v", err)
+	}
+	if id.Tenant != "team-a" || id.ClientID != "jwt:ci-bot" || len(id.Roles) != 2 {
+		t.Errorf("identity = %+v", id)
+	}
+
+	// HS256 с открытым ключом в роли секрета — классическая подмена алгоритма
+	hmacKey := key.PublicKey.X.Bytes()
+	cases := []struct {
+		name  string
+		token string
+	}{
+		{"wrong issuer", sign(jwt.SigningMethodES256, key, "k1", claims(func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }))},
+		{"wrong audience", sign(jwt.SigningMethodES256, key, "k1", claims(func(c jwt.MapClaims) { c["aud"] = "other" }))},
+		{"expired", sign(jwt.SigningMethodES256, key, "k1", claims(func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Hour).Unix() }))},
+		{"no exp", sign(jwt.SigningMethodES256, key, "k1", claims(func(c jwt.MapClaims) { delete(c, "exp") }))},
+		{"no sub", sign(jwt.SigningMethodES256, key, "k1", claims(func(c jwt.MapClaims) { delete(c, "sub") }))},
+		{"alg none", sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "k1", claims(nil))},
+		{"alg HS256", sign(jwt.SigningMethodHS256, hmacKey, "k1", claims(nil))},
+		{"foreign key", sign(jwt.SigningMethodES256, other, "k1", claims(nil))},
+		{"unknown kid", sign(jwt.SigningMethodES256, key, "k2", claims(nil))},
+		{"skipped kid", sign(jwt.SigningMethodES256, key, "p192", claims(nil))},
+	}
+	for _, tc := range cases {
+		if _, err := v.Validate(tc.token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: err = %v, want ErrInvalidToken", tc.name, err)
+		}
+	}
+}
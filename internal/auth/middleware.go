@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"sbom-serv/internal/config"
+	"sbom-serv/internal/logging"
+)
+
+var ErrNoCredentials = errors.New("missing credentials")
+
+// Authenticator определяет личность по запросу. ErrNoCredentials означает,
+// что запрос не содержит данных для этого способа.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Identity, error)
+}
+
+// BearerKeys — аутентификация по заголовку "Authorization: Bearer <api key>".
+type BearerKeys struct {
+	Keys *KeyStore
+}
+
+func (b BearerKeys) Authenticate(r *http.Request) (Identity, error) {
+	token, ok := BearerToken(r)
+	if !ok || !strings.HasPrefix(token, KeyPrefix) {
+		return Identity{}, ErrNoCredentials
+	}
+	return b.Keys.Lookup(r.Context(), token)
+}
+
+// Chain пробует способы по очереди, пока один из них не найдёт в запросе
+// свои данные.
+type Chain []Authenticator
+
+func (c Chain) Authenticate(r *http.Request) (Identity, error) {
+	for _, a := range c {
+		id, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return id, err
+	}
+	return Identity{}, ErrNoCredentials
+}
+
+func BearerToken(r *http.Request) (string, bool) {
+	h := r.Header.Get("Authorization")
+	scheme, token, ok := strings.Cut(h, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// Middleware требует аутентификацию для всех путей, кроме public.
+// Если authn == nil, все запросы выполняются от имени Anonymous.
+func Middleware(next http.Handler, authn Authenticator, public func(path string) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authn == nil {
+			next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), Anonymous)))
+			return
+		}
+		if public != nil && public(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		id, err := authn.Authenticate(r)
+		if errors.Is(err, ErrInvalidCert) {
+			http.Error(w, "client certificate is not accepted", http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			if !errors.Is(err, ErrNoCredentials) && !errors.Is(err, ErrInvalidKey) && !errors.Is(err, ErrInvalidToken) {
+				logging.FromContext(r.Context()).Error("authenticate", "component", "auth", "err", err)
+				http.Error(w, "authentication unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="sbom-serv"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if id.Tenant == "" {
+			id.Tenant = config.DefaultTenant
+		}
+		if !config.ValidTenant(id.Tenant) {
+			http.Error(w, "invalid tenant", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), id)))
+	})
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ErrInvalidCert — сертификат прошёл проверку цепочки, но по нему нельзя
+// определить клиента.
+var ErrInvalidCert = errors.New("invalid client certificate")
+
+// ClientCert — аутентификация по проверенному клиентскому сертификату (mTLS).
+// ClientID берётся из первого SAN (URI, DNS, email) или CN,
+// арендатор — из O, роли — из OU субъекта.
+type ClientCert struct{}
+
+func (ClientCert) Authenticate(r *http.Request) (Identity, error) {
+	// сертификаты без проверенной цепочки (VerifyClientCertIfGiven не пройден) не учитываем
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return Identity{}, ErrNoCredentials
+	}
+	return CertIdentity(r.TLS.VerifiedChains[0][0])
+}
+
+func CertIdentity(cert *x509.Certificate) (Identity, error) {
+	var name string
+	switch {
+	case len(cert.URIs) > 0:
+		name = cert.URIs[0].String()
+	case len(cert.DNSNames) > 0:
+		name = cert.DNSNames[0]
+	case len(cert.EmailAddresses) > 0:
+		name = cert.EmailAddresses[0]
+	default:
+		name = cert.Subject.CommonName
+	}
+	if name == "" {
+		return Identity{}, fmt.Errorf("%w: no SAN or CN", ErrInvalidCert)
+	}
+
+	id := Identity{
+		ClientID: "cert:" + name,
+		Name:     name,
+		Method:   "mtls",
+		Roles:    append([]string(nil), cert.Subject.OrganizationalUnit...),
+	}
+	if len(cert.Subject.Organization) > 0 {
+		id.Tenant = cert.Subject.Organization[0]
+	}
+	return id, nil
+}
+
+// ParseClientAuth переводит режим из конфигурации в tls.ClientAuthType:
+// none, optional (проверить, если предъявлен) или require.
+func ParseClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", "none":
+		return tls.NoClientCert, nil
+	case "optional":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	}
+	return tls.NoClientCert, fmt.Errorf("unknown client auth mode %q", mode)
+}
+
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s: no PEM certificates found", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	RoleUploader = "uploader"
+	RoleReader   = "reader"
+	RoleAdmin    = "admin"
+	// Для систем мониторинга: только /metrics и /status
+	RoleMonitor = "monitor"
+)
+
+type Permission string
+
+const (
+	PermScanCreate Permission = "scan:create"
+	PermScanRead   Permission = "scan:read"
+	// Отмена своих задач; чужих — только с PermScanCancelAny
+	PermScanCancel    Permission = "scan:cancel"
+	PermScanCancelAny Permission = "scan:cancel:any"
+	PermScanDelete    Permission = "scan:delete"
+	// Закреплённую задачу janitor не удаляет, поэтому закрепляет только admin
+	PermScanPin     Permission = "scan:pin"
+	PermJanitorRun  Permission = "janitor:run"
+	PermKeysManage  Permission = "keys:manage"
+	PermStatusRead  Permission = "status:read"
+	PermMetricsRead Permission = "metrics:read"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleUploader: {PermScanCreate, PermScanCancel},
+	RoleReader:   {PermScanRead},
+	RoleAdmin: {
+		PermScanCreate, PermScanRead, PermScanCancel, PermScanCancelAny,
+		PermScanDelete, PermScanPin, PermJanitorRun, PermKeysManage, PermStatusRead, PermMetricsRead,
+	},
+	RoleMonitor: {PermStatusRead, PermMetricsRead},
+}
+
+// Роли по умолчанию для новых API-ключей
+var DefaultKeyRoles = []string{RoleUploader, RoleReader}
+
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// ParseRoles разбирает список ролей через запятую.
+func ParseRoles(raw string) ([]string, error) {
+	var roles []string
+	for _, r := range strings.Split(raw, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		if !ValidRole(r) {
+			return nil, fmt.Errorf("unknown role %q", r)
+		}
+		if !slices.Contains(roles, r) {
+			roles = append(roles, r)
+		}
+	}
+	return roles, nil
+}
+
+// Can сообщает, даёт ли хотя бы одна из ролей личности разрешение.
+// Неизвестные роли (например, из claims IdP) игнорируются.
+func (id Identity) Can(p Permission) bool {
+	for _, role := range id.Roles {
+		if slices.Contains(rolePermissions[role], p) {
+			return true
+		}
+	}
+	return false
+}
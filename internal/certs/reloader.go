@@ -0,0 +1,122 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"sbom-serv/internal/logging"
+)
+
+// Reloader отдаёт серверный сертификат через tls.Config.GetCertificate и
+// перечитывает пару cert/key с диска, когда файлы меняются. Уже открытые
+// соединения не затрагиваются: новый сертификат используется со следующего handshake.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	log      *slog.Logger
+
+	mu    sync.RWMutex
+	cert  *tls.Certificate
+	stamp fileStamp
+}
+
+// fileStamp — признак изменения файлов. Сравниваются и время, и размер:
+// при замене через rename (как делают cert-manager и certbot) меняется хотя бы одно.
+type fileStamp struct {
+	certMod, keyMod   time.Time
+	certSize, keySize int64
+}
+
+// NewReloader загружает пару сразу, чтобы сервер не стартовал с битым сертификатом.
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		log:      logging.Component("tls"),
+	}
+	stamp, err := r.statFiles()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(stamp); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Start проверяет файлы раз в every до отмены ctx.
+func (r *Reloader) Start(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.check()
+		}
+	}
+}
+
+func (r *Reloader) check() {
+	stamp, err := r.statFiles()
+	if err != nil {
+		r.log.Error("stat certificate", "err", err)
+		return
+	}
+	r.mu.RLock()
+	same := stamp == r.stamp
+	r.mu.RUnlock()
+	if same {
+		return
+	}
+
+	// cert и key могут обновляться не одновременно: при ошибке остаётся старая пара,
+	// а stamp не сохраняется, так что попытка повторится на следующей проверке
+	if err := r.load(stamp); err != nil {
+		r.log.Error("reload certificate, keeping previous", "err", err)
+		return
+	}
+	r.log.Info("certificate reloaded", "cert_file", r.certFile)
+}
+
+func (r *Reloader) load(stamp fileStamp) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load key pair: %w", err)
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.stamp = stamp
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Reloader) statFiles() (fileStamp, error) {
+	ci, err := os.Stat(r.certFile)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	ki, err := os.Stat(r.keyFile)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{
+		certMod:  ci.ModTime(),
+		keyMod:   ki.ModTime(),
+		certSize: ci.Size(),
+		keySize:  ki.Size(),
+	}, nil
+}
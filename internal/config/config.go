@@ -0,0 +1,478 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config — настройки сервиса. Источники по возрастанию приоритета:
+// значения по умолчанию, YAML-файл, переменные окружения, флаги.
+type Config struct {
+	Server  ServerConfig  `yaml:"server"`
+	TLS     TLSConfig     `yaml:"tls"`
+	DB      DBConfig      `yaml:"db"`
+	Storage StorageConfig `yaml:"storage"`
+	Worker  WorkerConfig  `yaml:"worker"`
+	Janitor JanitorConfig `yaml:"janitor"`
+	Limits  LimitsConfig  `yaml:"limits"`
+	Auth    AuthConfig    `yaml:"auth"`
+	Webhook WebhookConfig `yaml:"webhook"`
+	CORS    CORSConfig    `yaml:"cors"`
+	Health  HealthConfig  `yaml:"health"`
+	Log     LogConfig     `yaml:"log"`
+	Tracing TracingConfig `yaml:"tracing"`
+	Vulns   VulnsConfig   `yaml:"vulns"`
+}
+
+type ServerConfig struct {
+	Addr              string   `yaml:"addr"`
+	ReadHeaderTimeout Duration `yaml:"read_header_timeout"`
+	ShutdownTimeout   Duration `yaml:"shutdown_timeout"`
+	OpenAPIFile       string   `yaml:"openapi_file"`
+}
+
+type TLSConfig struct {
+	// false — обычный HTTP, например за ingress, который сам терминирует TLS
+	Enabled  bool   `yaml:"enabled"`
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// Как часто проверять, не обновились ли cert_file и key_file
+	ReloadEvery Duration `yaml:"reload_every"`
+	// CA для клиентских сертификатов (mTLS); пусто — mTLS выключен
+	ClientCA string `yaml:"client_ca"`
+	// none | optional | require
+	ClientAuth string `yaml:"client_auth"`
+}
+
+type DBConfig struct {
+	// Пусто — параметры подключения берутся из PGHOST, PGUSER и т.д.
+	URL             string   `yaml:"url"`
+	MaxOpenConns    int      `yaml:"max_open_conns"`
+	MaxIdleConns    int      `yaml:"max_idle_conns"`
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime"`
+}
+
+type StorageConfig struct {
+	UploadsDir string `yaml:"uploads_dir"`
+}
+
+type WorkerConfig struct {
+	Parallel  int      `yaml:"parallel"`
+	PollEvery Duration `yaml:"poll_every"`
+	SyftPath  string   `yaml:"syft_path"`
+	// Ограничение времени одного скана (0 — без ограничения)
+	ScanTimeout Duration `yaml:"scan_timeout"`
+	MaxLogBytes int64    `yaml:"max_log_bytes"`
+}
+
+type JanitorConfig struct {
+	Every                Duration `yaml:"every"`
+	Retention            Duration `yaml:"retention"`
+	RunningTimeout       Duration `yaml:"running_timeout"`
+	RunningTimeoutAction string   `yaml:"running_timeout_action"`
+	TmpMaxAge            Duration `yaml:"tmp_max_age"`
+	BatchSize            int      `yaml:"batch_size"`
+	AdvisoryLockKey      int64    `yaml:"advisory_lock_key"`
+}
+
+// LimitsConfig — ограничения на входные архивы (0 — без ограничения).
+type LimitsConfig struct {
+	MaxUploadBytes   int64 `yaml:"max_upload_bytes"`
+	MaxUnpackedBytes int64 `yaml:"max_unpacked_bytes"`
+	MaxArchiveFiles  int   `yaml:"max_archive_files"`
+}
+
+type AuthConfig struct {
+	Disabled bool      `yaml:"disabled"`
+	JWT      JWTConfig `yaml:"jwt"`
+}
+
+// JWTConfig включается, если задан JWKSFile или JWKSURL.
+type JWTConfig struct {
+	JWKSFile    string   `yaml:"jwks_file"`
+	JWKSURL     string   `yaml:"jwks_url"`
+	CacheFile   string   `yaml:"cache_file"`
+	Issuer      string   `yaml:"issuer"`
+	Audience    string   `yaml:"audience"`
+	TenantClaim string   `yaml:"tenant_claim"`
+	RolesClaim  string   `yaml:"roles_claim"`
+	Refresh     Duration `yaml:"refresh"`
+}
+
+func (c JWTConfig) Enabled() bool {
+	return c.JWKSFile != "" || c.JWKSURL != ""
+}
+
+type WebhookConfig struct {
+	Every       Duration `yaml:"every"`
+	MaxAttempts int      `yaml:"max_attempts"`
+	BaseBackoff Duration `yaml:"base_backoff"`
+	MaxBackoff  Duration `yaml:"max_backoff"`
+	Timeout     Duration `yaml:"timeout"`
+	BatchSize   int      `yaml:"batch_size"`
+	// Внутренние получатели уведомлений: имена хостов, IP и подсети.
+	// Остальные loopback, приватные и link-local адреса запрещены
+	AllowedHosts []string `yaml:"allowed_hosts"`
+}
+
+// CORSConfig — доступ к API из браузера. Пустой allowed_origins выключает CORS.
+// Origin: точный ("https://ui.example.com"), маска поддоменов
+// ("https://*.example.com") или "*" (несовместимо с allow_credentials).
+type CORSConfig struct {
+	AllowedOrigins   []string `yaml:"allowed_origins"`
+	AllowedMethods   []string `yaml:"allowed_methods"`
+	AllowedHeaders   []string `yaml:"allowed_headers"`
+	ExposedHeaders   []string `yaml:"exposed_headers"`
+	AllowCredentials bool     `yaml:"allow_credentials"`
+	MaxAge           Duration `yaml:"max_age"`
+}
+
+// HealthConfig — проверки /readyz.
+type HealthConfig struct {
+	// Минимум свободного места в storage.uploads_dir (0 — не проверять)
+	MinFreeBytes int64 `yaml:"min_free_bytes"`
+	// Как долго считать актуальной проверку сканера (запуск "syft version")
+	ScannerCheckEvery Duration `yaml:"scanner_check_every"`
+	Timeout           Duration `yaml:"timeout"`
+}
+
+type LogConfig struct {
+	// debug, info, warn или error
+	Level string `yaml:"level"`
+	// json или text
+	Format string `yaml:"format"`
+}
+
+// TracingConfig — экспорт спанов OpenTelemetry.
+type TracingConfig struct {
+	// none, otlp (OTLP/HTTP) или stdout (JSON в file или в stdout)
+	Exporter string `yaml:"exporter"`
+	// Адрес коллектора для otlp, например http://otel-collector:4318;
+	// пусто — OTEL_EXPORTER_OTLP_ENDPOINT или localhost:4318
+	Endpoint string `yaml:"endpoint"`
+	File     string `yaml:"file"`
+	// Доля записываемых трасс (0..1) для запросов без входящего traceparent
+	SampleRatio float64 `yaml:"sample_ratio"`
+	ServiceName string  `yaml:"service_name"`
+}
+
+// VulnsConfig — сопоставление SBOM с офлайн-базой уязвимостей OSV.
+type VulnsConfig struct {
+	// Включать после загрузки базы (sbom-serv vulndb import): иначе каждая
+	// задача получит пустой отчёт
+	Enabled bool `yaml:"enabled"`
+	// Как часто проверять обновление базы и сопоставлять закреплённые задачи
+	WatchEvery           Duration `yaml:"watch_every"`
+	WatchAdvisoryLockKey int64    `yaml:"watch_advisory_lock_key"`
+}
+
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:              ":8082",
+			ReadHeaderTimeout: Duration(10 * time.Second),
+			ShutdownTimeout:   Duration(10 * time.Second),
+			OpenAPIFile:       "./docs/openapi.yaml",
+		},
+		TLS: TLSConfig{
+			Enabled:     true,
+			CertFile:    "/app/certs/fullchain.pem",
+			KeyFile:     "/app/certs/tvles-dintp0005.esrt.sber.ru.key",
+			ReloadEvery: Duration(30 * time.Second),
+			ClientAuth:  "require",
+		},
+		DB: DBConfig{
+			MaxOpenConns:    20,
+			MaxIdleConns:    20,
+			ConnMaxLifetime: Duration(30 * time.Minute),
+		},
+		Storage: StorageConfig{
+			UploadsDir: "./uploads",
+		},
+		Worker: WorkerConfig{
+			Parallel:    5,
+			PollEvery:   Duration(300 * time.Millisecond),
+			SyftPath:    "syft",
+			MaxLogBytes: 1 << 20,
+		},
+		Janitor: JanitorConfig{
+			Every:                Duration(1 * time.Hour),
+			Retention:            Duration(24 * time.Hour),
+			RunningTimeout:       Duration(3 * time.Hour),
+			RunningTimeoutAction: "fail",
+			TmpMaxAge:            Duration(10 * time.Minute),
+			BatchSize:            500,
+			AdvisoryLockKey:      9876543,
+		},
+		Limits: LimitsConfig{
+			MaxUploadBytes:   1 << 30,
+			MaxUnpackedBytes: 8 << 30,
+			MaxArchiveFiles:  500000,
+		},
+		Auth: AuthConfig{
+			JWT: JWTConfig{
+				TenantClaim: "tenant",
+				RolesClaim:  "roles",
+				Refresh:     Duration(10 * time.Minute),
+			},
+		},
+		Webhook: WebhookConfig{
+			Every:        Duration(5 * time.Second),
+			MaxAttempts:  10,
+			BaseBackoff:  Duration(10 * time.Second),
+			MaxBackoff:   Duration(1 * time.Hour),
+			Timeout:      Duration(10 * time.Second),
+			BatchSize:    20,
+			AllowedHosts: []string{},
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{},
+			AllowedMethods: []string{"GET", "POST", "DELETE"},
+			AllowedHeaders: []string{"Authorization", "Content-Type", "X-Callback-Secret", "X-Request-ID", "traceparent"},
+			ExposedHeaders: []string{"X-Request-ID", "X-Policy-Verdict", "X-Zip-ID", "X-Project-Version"},
+			MaxAge:         Duration(10 * time.Minute),
+		},
+		Health: HealthConfig{
+			MinFreeBytes:      1 << 30,
+			ScannerCheckEvery: Duration(1 * time.Minute),
+			Timeout:           Duration(5 * time.Second),
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			SampleRatio: 1,
+			ServiceName: "sbom-serv",
+		},
+		Vulns: VulnsConfig{
+			WatchEvery:           Duration(10 * time.Minute),
+			WatchAdvisoryLockKey: 9876544,
+		},
+	}
+}
+
+// Load читает файл (если path не пуст), затем переменные окружения и
+// переопределения из флагов, и проверяет результат.
+func Load(path string, flags *Flags) (Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("read config: %w", err)
+		}
+		if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+			return Config{}, fmt.Errorf("parse config %s: %w", path, err)
+		}
+	}
+
+	for _, s := range settings {
+		v, ok := os.LookupEnv(s.envName())
+		if !ok {
+			continue
+		}
+		if err := s.set(&cfg, v); err != nil {
+			return Config{}, fmt.Errorf("env %s: %w", s.envName(), err)
+		}
+	}
+
+	if flags != nil {
+		for _, o := range flags.set {
+			if err := o.s.set(&cfg, o.value); err != nil {
+				return Config{}, fmt.Errorf("flag -%s: %w", o.s.key, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Addr != "", "server.addr is required")
+	check(c.Server.ReadHeaderTimeout > 0, "server.read_header_timeout must be positive")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+
+	if c.TLS.Enabled {
+		check(c.TLS.CertFile != "" && c.TLS.KeyFile != "", "tls.cert_file and tls.key_file are required")
+		check(c.TLS.ReloadEvery > 0, "tls.reload_every must be positive")
+	} else {
+		check(c.TLS.ClientCA == "", "tls.client_ca requires tls.enabled")
+	}
+	switch c.TLS.ClientAuth {
+	case "none", "optional", "require":
+	default:
+		check(false, "tls.client_auth must be none, optional or require, got %q", c.TLS.ClientAuth)
+	}
+
+	check(c.DB.MaxOpenConns > 0, "db.max_open_conns must be positive")
+	check(c.DB.MaxIdleConns >= 0 && c.DB.MaxIdleConns <= c.DB.MaxOpenConns,
+		"db.max_idle_conns must be between 0 and db.max_open_conns")
+	check(c.DB.ConnMaxLifetime >= 0, "db.conn_max_lifetime must not be negative")
+
+	check(c.Storage.UploadsDir != "", "storage.uploads_dir is required")
+
+	check(c.Worker.Parallel > 0, "worker.parallel must be positive")
+	check(c.Worker.PollEvery > 0, "worker.poll_every must be positive")
+	check(c.Worker.SyftPath != "", "worker.syft_path is required")
+	check(c.Worker.ScanTimeout >= 0, "worker.scan_timeout must not be negative")
+	check(c.Worker.MaxLogBytes > 0, "worker.max_log_bytes must be positive")
+
+	check(c.Janitor.Every > 0, "janitor.every must be positive")
+	check(c.Janitor.Retention >= 0, "janitor.retention must not be negative")
+	check(c.Janitor.RunningTimeout >= 0, "janitor.running_timeout must not be negative")
+	check(c.Janitor.RunningTimeoutAction == "fail" || c.Janitor.RunningTimeoutAction == "requeue",
+		"janitor.running_timeout_action must be fail or requeue, got %q", c.Janitor.RunningTimeoutAction)
+	check(c.Janitor.TmpMaxAge > 0, "janitor.tmp_max_age must be positive")
+	check(c.Janitor.BatchSize > 0, "janitor.batch_size must be positive")
+
+	check(c.Limits.MaxUploadBytes >= 0, "limits.max_upload_bytes must not be negative")
+	check(c.Limits.MaxUnpackedBytes >= 0, "limits.max_unpacked_bytes must not be negative")
+	check(c.Limits.MaxArchiveFiles >= 0, "limits.max_archive_files must not be negative")
+
+	if c.Auth.JWT.JWKSURL != "" {
+		u, err := url.Parse(c.Auth.JWT.JWKSURL)
+		check(err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != "",
+			"auth.jwt.jwks_url must be an http(s) URL")
+	}
+	check(c.Auth.JWT.Refresh > 0, "auth.jwt.refresh must be positive")
+
+	check(c.Webhook.Every > 0, "webhook.every must be positive")
+	check(c.Webhook.MaxAttempts > 0, "webhook.max_attempts must be positive")
+	check(c.Webhook.BaseBackoff > 0 && c.Webhook.BaseBackoff <= c.Webhook.MaxBackoff,
+		"webhook.base_backoff must be positive and not exceed webhook.max_backoff")
+	check(c.Webhook.Timeout > 0, "webhook.timeout must be positive")
+	check(c.Webhook.BatchSize > 0, "webhook.batch_size must be positive")
+	for _, h := range c.Webhook.AllowedHosts {
+		_, err := netip.ParsePrefix(h)
+		check(h != "" && (!strings.Contains(h, "/") || err == nil),
+			"webhook.allowed_hosts: invalid host or CIDR %q", h)
+	}
+
+	for _, o := range c.CORS.AllowedOrigins {
+		if o == "*" {
+			check(!c.CORS.AllowCredentials, "cors.allowed_origins \"*\" cannot be combined with cors.allow_credentials")
+			continue
+		}
+		check(validOriginPattern(o), "cors.allowed_origins: invalid origin %q (want scheme://host[:port])", o)
+	}
+	check(len(c.CORS.AllowedOrigins) == 0 || len(c.CORS.AllowedMethods) > 0, "cors.allowed_methods must not be empty")
+	check(c.CORS.MaxAge >= 0, "cors.max_age must not be negative")
+
+	check(c.Health.MinFreeBytes >= 0, "health.min_free_bytes must not be negative")
+	check(c.Health.ScannerCheckEvery > 0, "health.scanner_check_every must be positive")
+	check(c.Health.Timeout > 0, "health.timeout must be positive")
+
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		check(false, "log.level must be one of debug, info, warn, error")
+	}
+	check(c.Log.Format == "json" || c.Log.Format == "text", "log.format must be json or text")
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if c.Tracing.Endpoint != "" {
+			u, err := url.Parse(c.Tracing.Endpoint)
+			check(err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != "",
+				"tracing.endpoint must be an http(s) URL")
+		}
+	default:
+		check(false, "tracing.exporter must be one of none, otlp, stdout")
+	}
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio must be between 0 and 1")
+	check(c.Tracing.ServiceName != "", "tracing.service_name must not be empty")
+
+	check(c.Vulns.WatchEvery > 0, "vulns.watch_every must be positive")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// validOriginPattern: "scheme://host[:port]" без пути, host может начинаться с "*.".
+func validOriginPattern(s string) bool {
+	u, err := url.Parse(strings.Replace(s, "://*.", "://wildcard.", 1))
+	return err == nil && u.Scheme != "" && u.Host != "" && u.Path == "" &&
+		u.User == nil && u.RawQuery == "" && u.Fragment == "" && !strings.Contains(u.Host, "*")
+}
+
+// Redacted возвращает копию без секретов — для --print-config и логов.
+func (c Config) Redacted() Config {
+	if c.DB.URL != "" {
+		c.DB.URL = redactURL(c.DB.URL)
+	}
+	return c
+}
+
+// YAML — конфигурация в формате файла.
+func (c Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
+}
+
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" {
+		// DSN вида "host=... password=..." — не разбираем, прячем целиком
+		return "REDACTED"
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "REDACTED")
+	}
+	q := u.Query()
+	if q.Has("password") {
+		q.Set("password", "REDACTED")
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+// Duration читается и печатается в виде "90s", "10m", "24h".
+type Duration time.Duration
+
+func (d Duration) D() time.Duration { return time.Duration(d) }
+
+func (d Duration) MarshalYAML() (any, error) {
+	return time.Duration(d).String(), nil
+}
+
+func (d *Duration) UnmarshalYAML(unmarshal func(any) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	v, err := parseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "0" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
@@ -1,14 +1,18 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"regexp"
 )
 
 type UploadPaths struct {
 	Base    string
 	Zips    string
 	Results string
+	Logs    string
+	Work    string
 }
 
 func NewUploadPaths(base string) UploadPaths {
@@ -16,6 +20,8 @@ func NewUploadPaths(base string) UploadPaths {
 		Base:    base,
 		Zips:    filepath.Join(base, "zips"),
 		Results: filepath.Join(base, "results"),
+		Logs:    filepath.Join(base, "logs"),
+		Work:    filepath.Join(base, "work"),
 	}
 }
 
@@ -26,5 +32,75 @@ func (p UploadPaths) Ensure() error {
 	if err := os.MkdirAll(p.Results, 0o755); err != nil {
 		return err
 	}
+	if err := os.MkdirAll(p.Logs, 0o755); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(p.Work, 0o755); err != nil {
+		return err
+	}
 	return nil
 }
+
+// ResultPath — SBOM, построенный для задачи.
+func (p UploadPaths) ResultPath(id string) string {
+	return filepath.Join(p.Results, "result-"+id+".json")
+}
+
+func (p UploadPaths) LogPath(id string) string {
+	return filepath.Join(p.Logs, "log-"+id+".log")
+}
+
+// WorkDir — каталог, куда воркер распаковывает архив задачи.
+func (p UploadPaths) WorkDir(id string) string {
+	return filepath.Join(p.Work, id)
+}
+
+// Арендатор по умолчанию хранит файлы прямо в Base, как до появления арендаторов.
+const DefaultTenant = "default"
+
+var tenantRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// ValidTenant проверяет, что имя арендатора безопасно использовать как каталог.
+func ValidTenant(t string) bool {
+	return tenantRe.MatchString(t)
+}
+
+// Tenant возвращает каталоги арендатора: <base>/tenants/<tenant>/...
+func (p UploadPaths) Tenant(t string) UploadPaths {
+	if t == "" || t == DefaultTenant {
+		return p
+	}
+	return NewUploadPaths(filepath.Join(p.Base, "tenants", t))
+}
+
+// AllTenants — каталоги всех арендаторов, у которых есть файлы на диске.
+func (p UploadPaths) AllTenants() ([]UploadPaths, error) {
+	out := []UploadPaths{p}
+	entries, err := os.ReadDir(filepath.Join(p.Base, "tenants"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return out, nil
+		}
+		return out, err
+	}
+	for _, e := range entries {
+		if e.IsDir() && ValidTenant(e.Name()) {
+			out = append(out, p.Tenant(e.Name()))
+		}
+	}
+	return out, nil
+}
+
+// RemoveTask удаляет все файлы задачи: архив, результат, лог и рабочий каталог.
+func (p UploadPaths) RemoveTask(id string) {
+	for _, path := range []string{
+		p.ResultPath(id),
+		p.ResultPath(id) + ".tmp",
+		filepath.Join(p.Zips, "zip-"+id+".zip"),
+		filepath.Join(p.Zips, "zip-"+id+".zip.tmp"),
+		p.LogPath(id),
+	} {
+		_ = os.Remove(path)
+	}
+	_ = os.RemoveAll(p.WorkDir(id))
+}
@@ -0,0 +1,184 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// setting описывает одну настройку: ключ совпадает с путём в YAML и именем флага,
+// переменная окружения по умолчанию — SBOM_<КЛЮЧ>. Для старых переменных
+// (DATABASE_URL, JWKS_FILE, ...) имя задано явно, чтобы не ломать развёртывания.
+type setting struct {
+	key   string
+	env   string
+	usage string
+	ptr   func(c *Config) any
+}
+
+func (s setting) envName() string {
+	if s.env != "" {
+		return s.env
+	}
+	return "SBOM_" + strings.ToUpper(strings.NewReplacer(".", "_").Replace(s.key))
+}
+
+func (s setting) set(c *Config, raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch p := s.ptr(c).(type) {
+	case *string:
+		*p = raw
+	case *bool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		*p = v
+	case *int:
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		*p = v
+	case *int64:
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		*p = v
+	case *float64:
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		*p = v
+	case *[]string:
+		*p = []string{}
+		for _, v := range strings.Split(raw, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				*p = append(*p, v)
+			}
+		}
+	case *Duration:
+		v, err := parseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		*p = Duration(v)
+	default:
+		return fmt.Errorf("unsupported setting type %T", p)
+	}
+	return nil
+}
+
+var settings = []setting{
+	{key: "server.addr", usage: "listen address", ptr: func(c *Config) any { return &c.Server.Addr }},
+	{key: "server.read_header_timeout", ptr: func(c *Config) any { return &c.Server.ReadHeaderTimeout }},
+	{key: "server.shutdown_timeout", ptr: func(c *Config) any { return &c.Server.ShutdownTimeout }},
+	{key: "server.openapi_file", ptr: func(c *Config) any { return &c.Server.OpenAPIFile }},
+
+	{key: "tls.enabled", usage: "serve HTTPS; false — plain HTTP behind a TLS-terminating proxy", ptr: func(c *Config) any { return &c.TLS.Enabled }},
+	{key: "tls.cert_file", usage: "server certificate (PEM)", ptr: func(c *Config) any { return &c.TLS.CertFile }},
+	{key: "tls.key_file", usage: "server private key (PEM)", ptr: func(c *Config) any { return &c.TLS.KeyFile }},
+	{key: "tls.reload_every", usage: "how often to check certificate files for changes", ptr: func(c *Config) any { return &c.TLS.ReloadEvery }},
+	{key: "tls.client_ca", env: "TLS_CLIENT_CA", usage: "CA bundle for client certificates (enables mTLS)", ptr: func(c *Config) any { return &c.TLS.ClientCA }},
+	{key: "tls.client_auth", env: "TLS_CLIENT_AUTH", usage: "none|optional|require", ptr: func(c *Config) any { return &c.TLS.ClientAuth }},
+
+	{key: "db.url", env: "DATABASE_URL", usage: "PostgreSQL connection string", ptr: func(c *Config) any { return &c.DB.URL }},
+	{key: "db.max_open_conns", ptr: func(c *Config) any { return &c.DB.MaxOpenConns }},
+	{key: "db.max_idle_conns", ptr: func(c *Config) any { return &c.DB.MaxIdleConns }},
+	{key: "db.conn_max_lifetime", ptr: func(c *Config) any { return &c.DB.ConnMaxLifetime }},
+
+	{key: "storage.uploads_dir", usage: "directory for archives, results and logs", ptr: func(c *Config) any { return &c.Storage.UploadsDir }},
+
+	{key: "worker.parallel", usage: "number of concurrent scans", ptr: func(c *Config) any { return &c.Worker.Parallel }},
+	{key: "worker.poll_every", ptr: func(c *Config) any { return &c.Worker.PollEvery }},
+	{key: "worker.syft_path", ptr: func(c *Config) any { return &c.Worker.SyftPath }},
+	{key: "worker.scan_timeout", ptr: func(c *Config) any { return &c.Worker.ScanTimeout }},
+	{key: "worker.max_log_bytes", ptr: func(c *Config) any { return &c.Worker.MaxLogBytes }},
+
+	{key: "janitor.every", ptr: func(c *Config) any { return &c.Janitor.Every }},
+	{key: "janitor.retention", ptr: func(c *Config) any { return &c.Janitor.Retention }},
+	{key: "janitor.running_timeout", ptr: func(c *Config) any { return &c.Janitor.RunningTimeout }},
+	{key: "janitor.running_timeout_action", usage: "fail|requeue", ptr: func(c *Config) any { return &c.Janitor.RunningTimeoutAction }},
+	{key: "janitor.tmp_max_age", usage: "delete *.tmp files and unfinished uploads older than this", ptr: func(c *Config) any { return &c.Janitor.TmpMaxAge }},
+	{key: "janitor.batch_size", ptr: func(c *Config) any { return &c.Janitor.BatchSize }},
+	{key: "janitor.advisory_lock_key", ptr: func(c *Config) any { return &c.Janitor.AdvisoryLockKey }},
+
+	{key: "limits.max_upload_bytes", ptr: func(c *Config) any { return &c.Limits.MaxUploadBytes }},
+	{key: "limits.max_unpacked_bytes", ptr: func(c *Config) any { return &c.Limits.MaxUnpackedBytes }},
+	{key: "limits.max_archive_files", ptr: func(c *Config) any { return &c.Limits.MaxArchiveFiles }},
+
+	{key: "auth.disabled", env: "AUTH_DISABLED", usage: "disable authentication (development only)", ptr: func(c *Config) any { return &c.Auth.Disabled }},
+	{key: "auth.jwt.jwks_file", env: "JWKS_FILE", ptr: func(c *Config) any { return &c.Auth.JWT.JWKSFile }},
+	{key: "auth.jwt.jwks_url", env: "JWKS_URL", ptr: func(c *Config) any { return &c.Auth.JWT.JWKSURL }},
+	{key: "auth.jwt.cache_file", env: "JWKS_CACHE_FILE", ptr: func(c *Config) any { return &c.Auth.JWT.CacheFile }},
+	{key: "auth.jwt.issuer", env: "JWT_ISSUER", ptr: func(c *Config) any { return &c.Auth.JWT.Issuer }},
+	{key: "auth.jwt.audience", env: "JWT_AUDIENCE", ptr: func(c *Config) any { return &c.Auth.JWT.Audience }},
+	{key: "auth.jwt.tenant_claim", env: "JWT_TENANT_CLAIM", ptr: func(c *Config) any { return &c.Auth.JWT.TenantClaim }},
+	{key: "auth.jwt.roles_claim", env: "JWT_ROLES_CLAIM", ptr: func(c *Config) any { return &c.Auth.JWT.RolesClaim }},
+	{key: "auth.jwt.refresh", ptr: func(c *Config) any { return &c.Auth.JWT.Refresh }},
+
+	{key: "webhook.every", ptr: func(c *Config) any { return &c.Webhook.Every }},
+	{key: "webhook.max_attempts", ptr: func(c *Config) any { return &c.Webhook.MaxAttempts }},
+	{key: "webhook.base_backoff", ptr: func(c *Config) any { return &c.Webhook.BaseBackoff }},
+	{key: "webhook.max_backoff", ptr: func(c *Config) any { return &c.Webhook.MaxBackoff }},
+	{key: "webhook.timeout", ptr: func(c *Config) any { return &c.Webhook.Timeout }},
+	{key: "webhook.batch_size", ptr: func(c *Config) any { return &c.Webhook.BatchSize }},
+	{key: "webhook.allowed_hosts", usage: "comma-separated internal hosts, IPs or CIDRs allowed as callback_url", ptr: func(c *Config) any { return &c.Webhook.AllowedHosts }},
+
+	{key: "cors.allowed_origins", usage: "comma-separated origins allowed to call the API from a browser", ptr: func(c *Config) any { return &c.CORS.AllowedOrigins }},
+	{key: "cors.allowed_methods", ptr: func(c *Config) any { return &c.CORS.AllowedMethods }},
+	{key: "cors.allowed_headers", ptr: func(c *Config) any { return &c.CORS.AllowedHeaders }},
+	{key: "cors.exposed_headers", ptr: func(c *Config) any { return &c.CORS.ExposedHeaders }},
+	{key: "cors.allow_credentials", ptr: func(c *Config) any { return &c.CORS.AllowCredentials }},
+	{key: "cors.max_age", ptr: func(c *Config) any { return &c.CORS.MaxAge }},
+
+	{key: "health.min_free_bytes", usage: "minimum free disk space for /readyz", ptr: func(c *Config) any { return &c.Health.MinFreeBytes }},
+	{key: "health.scanner_check_every", ptr: func(c *Config) any { return &c.Health.ScannerCheckEvery }},
+	{key: "health.timeout", ptr: func(c *Config) any { return &c.Health.Timeout }},
+
+	{key: "log.level", usage: "debug, info, warn or error", ptr: func(c *Config) any { return &c.Log.Level }},
+	{key: "log.format", usage: "json or text", ptr: func(c *Config) any { return &c.Log.Format }},
+
+	{key: "tracing.exporter", usage: "none, otlp or stdout", ptr: func(c *Config) any { return &c.Tracing.Exporter }},
+	{key: "tracing.endpoint", usage: "OTLP/HTTP collector URL", ptr: func(c *Config) any { return &c.Tracing.Endpoint }},
+	{key: "tracing.file", usage: "file for the stdout exporter (empty = stdout)", ptr: func(c *Config) any { return &c.Tracing.File }},
+	{key: "tracing.sample_ratio", ptr: func(c *Config) any { return &c.Tracing.SampleRatio }},
+	{key: "tracing.service_name", ptr: func(c *Config) any { return &c.Tracing.ServiceName }},
+
+	{key: "vulns.enabled", usage: "match SBOMs against the offline vulnerability database", ptr: func(c *Config) any { return &c.Vulns.Enabled }},
+	{key: "vulns.watch_every", usage: "how often to re-match pinned tasks after a vulnerability DB refresh", ptr: func(c *Config) any { return &c.Vulns.WatchEvery }},
+	{key: "vulns.watch_advisory_lock_key", ptr: func(c *Config) any { return &c.Vulns.WatchAdvisoryLockKey }},
+}
+
+// Flags собирает значения флагов -<ключ> в порядке их указания.
+type Flags struct {
+	set []flagValue
+}
+
+type flagValue struct {
+	s     setting
+	value string
+}
+
+// RegisterFlags добавляет в fs по флагу на каждую настройку.
+// Значения применяются в Load поверх файла и окружения.
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	for _, s := range settings {
+		usage := s.usage
+		if usage == "" {
+			usage = s.key
+		}
+		fs.Func(s.key, usage+" (env "+s.envName()+")", func(v string) error {
+			if err := s.set(&Config{}, v); err != nil {
+				return err
+			}
+			f.set = append(f.set, flagValue{s: s, value: v})
+			return nil
+		})
+	}
+	return f
+}
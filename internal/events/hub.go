@@ -0,0 +1,209 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+
+	"sbom-serv/internal/logging"
+	"sbom-serv/internal/taskstore"
+)
+
+// Канал pg_notify, в который пишет триггер sbom_tasks_notify.
+const Channel = "sbom_task_events"
+
+// Event — снимок задачи после изменения. Resync означает, что часть
+// уведомлений могла потеряться и подписчику нужно перечитать состояние из БД.
+type Event struct {
+	ID       string    `json:"id"`
+	Tenant   string    `json:"tenant"`
+	Status   string    `json:"status"`
+	Stage    *string   `json:"stage,omitempty"`
+	Progress *int      `json:"progress,omitempty"`
+	Packages *int      `json:"packages,omitempty"`
+	Project  *string   `json:"project,omitempty"`
+	Error    *string   `json:"error,omitempty"`
+	TS       time.Time `json:"ts"`
+
+	Resync bool `json:"-"`
+}
+
+// StatusDeleted — статус события об удалении задачи (DELETE /scan/{id}, janitor).
+const StatusDeleted = "deleted"
+
+func (e Event) Terminal() bool {
+	return e.Status == string(taskstore.StatusDone) || e.Status == string(taskstore.StatusFailed) ||
+		e.Status == StatusDeleted
+}
+
+// Deleted — событие об удалении задачи, которое подписчик восстановил сам,
+// не найдя задачу после Resync.
+func Deleted(last Event) Event {
+	return Event{ID: last.ID, Tenant: last.Tenant, Status: StatusDeleted, Project: last.Project, TS: time.Now().UTC()}
+}
+
+func FromTask(t taskstore.Task) Event {
+	ev := Event{
+		ID:       t.ID,
+		Tenant:   t.Tenant,
+		Status:   string(t.Status),
+		Progress: t.Progress,
+		Packages: t.Packages,
+		Project:  t.Project,
+		Error:    t.Error,
+		TS:       t.Timestamp,
+	}
+	if t.Stage != nil {
+		stage := string(*t.Stage)
+		ev.Stage = &stage
+	}
+	return ev
+}
+
+// Hub слушает уведомления Postgres и раздаёт их подписчикам внутри процесса.
+// Каждая реплика API держит своё LISTEN-соединение, поэтому изменения,
+// сделанные воркером на любой реплике, видны всем.
+type Hub struct {
+	db  *sql.DB
+	log *slog.Logger
+
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+	done chan struct{}
+}
+
+func NewHub(db *sql.DB) *Hub {
+	return &Hub{
+		db:   db,
+		log:  logging.Component("events"),
+		subs: make(map[*Subscription]struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+type Subscription struct {
+	C     <-chan Event
+	ch    chan Event
+	match func(Event) bool
+}
+
+// Subscribe возвращает подписку на события, для которых match вернул true.
+// Resync-события получают все подписчики.
+func (h *Hub) Subscribe(match func(Event) bool) *Subscription {
+	ch := make(chan Event, 64)
+	s := &Subscription{C: ch, ch: ch, match: match}
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	delete(h.subs, s)
+	h.mu.Unlock()
+}
+
+// Done закрывается при остановке Hub — долгие запросы должны завершиться.
+func (h *Hub) Done() <-chan struct{} {
+	return h.done
+}
+
+func (h *Hub) Run(ctx context.Context) {
+	defer close(h.done)
+
+	backoff := time.Second
+	for {
+		started := time.Now()
+		err := h.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		h.log.Error("listen failed, reconnecting", "backoff", backoff, "err", err)
+
+		if time.Since(started) > time.Minute {
+			backoff = time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func (h *Hub) listen(ctx context.Context) error {
+	conn, err := h.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	err = conn.Raw(func(dc any) error {
+		// соединение в режиме LISTEN нельзя возвращать в пул:
+		// ErrBadConn заставляет database/sql его закрыть
+		pc := dc.(*stdlib.Conn).Conn()
+		if _, err := pc.Exec(ctx, "LISTEN "+Channel); err != nil {
+			return errors.Join(err, driver.ErrBadConn)
+		}
+		// пока соединения не было, уведомления терялись
+		h.broadcast(Event{Resync: true})
+
+		for {
+			n, err := pc.WaitForNotification(ctx)
+			if err != nil {
+				return errors.Join(err, driver.ErrBadConn)
+			}
+			var ev Event
+			if err := json.Unmarshal([]byte(n.Payload), &ev); err != nil {
+				h.log.Warn("bad notification payload", "err", err)
+				continue
+			}
+			h.broadcast(ev)
+		}
+	})
+	return fmt.Errorf("listen %s: %w", Channel, err)
+}
+
+func (h *Hub) broadcast(ev Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+		if !ev.Resync && !s.match(ev) {
+			continue
+		}
+		s.send(ev)
+	}
+}
+
+func (s *Subscription) send(ev Event) {
+	select {
+	case s.ch <- ev:
+		return
+	default:
+	}
+	// подписчик не успевает: выбрасываем очередь и просим перечитать состояние
+drain:
+	for {
+		select {
+		case <-s.ch:
+		default:
+			break drain
+		}
+	}
+	select {
+	case s.ch <- Event{Resync: true}:
+	default:
+	}
+}
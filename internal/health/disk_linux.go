@@ -0,0 +1,14 @@
+//go:build linux
+
+package health
+
+import "syscall"
+
+func freeBytes(path string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	// Bavail — место, доступное непривилегированному процессу
+	return st.Bavail * uint64(st.Bsize), nil
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package health
+
+func freeBytes(string) (uint64, error) {
+	return 0, errUnsupported
+}
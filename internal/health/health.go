@@ -0,0 +1,248 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"sbom-serv/internal/config"
+)
+
+type Config struct {
+	// Минимум свободного места в каталоге uploads (0 = не проверять)
+	MinFreeBytes int64
+
+	// Путь к сканеру и как долго считать результат его проверки актуальным
+	SyftPath          string
+	ScannerCheckEvery time.Duration
+
+	// Ограничение времени одной проверки
+	Timeout time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		MinFreeBytes:      1 << 30,
+		SyftPath:          "syft",
+		ScannerCheckEvery: 1 * time.Minute,
+		Timeout:           5 * time.Second,
+	}
+}
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+	// Проверка недоступна на этой платформе
+	StatusSkipped = "skipped"
+)
+
+var errUnsupported = errors.New("not supported on this platform")
+
+type Result struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	// Дополнительные сведения: свободное место, версия сканера
+	Details map[string]any `json:"details,omitempty"`
+}
+
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+func (r Report) OK() bool { return r.Status == StatusOK }
+
+// Summary — отчёт без ошибок и подробностей, для проверок без аутентификации:
+// тексты ошибок БД, пути и версия сканера наружу не отдаются.
+type Summary struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+func (r Report) Summary() Summary {
+	s := Summary{Status: r.Status, Checks: make(map[string]string, len(r.Checks))}
+	for name, res := range r.Checks {
+		s.Checks[name] = res.Status
+	}
+	return s
+}
+
+// Checker проверяет зависимости, без которых инстанс не может обрабатывать задачи.
+type Checker struct {
+	db    *sql.DB
+	paths config.UploadPaths
+	cfg   Config
+
+	mu          sync.Mutex
+	scanner     Result
+	scannerAt   time.Time
+	scannerBusy chan struct{}
+}
+
+func New(db *sql.DB, paths config.UploadPaths, cfg Config) *Checker {
+	c := &Checker{
+		db:          db,
+		paths:       paths,
+		cfg:         cfg,
+		scannerBusy: make(chan struct{}, 1),
+	}
+	def := DefaultConfig()
+	if c.cfg.SyftPath == "" {
+		c.cfg.SyftPath = def.SyftPath
+	}
+	if c.cfg.ScannerCheckEvery <= 0 {
+		c.cfg.ScannerCheckEvery = def.ScannerCheckEvery
+	}
+	if c.cfg.Timeout <= 0 {
+		c.cfg.Timeout = def.Timeout
+	}
+	return c
+}
+
+// Check выполняет все проверки параллельно.
+func (c *Checker) Check(ctx context.Context) Report {
+	checks := map[string]func(context.Context) Result{
+		"database": c.checkDB,
+		"uploads":  c.checkUploads,
+		"disk":     c.checkDisk,
+		"scanner":  c.checkScanner,
+	}
+
+	rep := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, fn := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+			defer cancel()
+			res := fn(cctx)
+
+			mu.Lock()
+			rep.Checks[name] = res
+			if res.Status == StatusFail {
+				rep.Status = StatusFail
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return rep
+}
+
+func timed(fn func() (map[string]any, error)) Result {
+	started := time.Now()
+	details, err := fn()
+	res := Result{Status: StatusOK, Details: details, DurationMs: time.Since(started).Milliseconds()}
+	if errors.Is(err, errUnsupported) {
+		res.Status = StatusSkipped
+	} else if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+	return res
+}
+
+func (c *Checker) checkDB(ctx context.Context) Result {
+	return timed(func() (map[string]any, error) {
+		return nil, c.db.PingContext(ctx)
+	})
+}
+
+// checkUploads создаёт и удаляет файл: каталог может существовать,
+// но быть смонтирован только на чтение.
+func (c *Checker) checkUploads(context.Context) Result {
+	return timed(func() (map[string]any, error) {
+		f, err := os.CreateTemp(c.paths.Base, ".readyz-*.tmp")
+		if err != nil {
+			return nil, err
+		}
+		name := f.Name()
+		_, werr := f.Write([]byte("ok"))
+		cerr := f.Close()
+		rerr := os.Remove(name)
+		return nil, errors.Join(werr, cerr, rerr)
+	})
+}
+
+func (c *Checker) checkDisk(context.Context) Result {
+	return timed(func() (map[string]any, error) {
+		free, err := freeBytes(c.paths.Base)
+		if err != nil {
+			return nil, err
+		}
+		details := map[string]any{"free_bytes": free, "min_free_bytes": c.cfg.MinFreeBytes}
+		if c.cfg.MinFreeBytes > 0 && free < uint64(c.cfg.MinFreeBytes) {
+			return details, fmt.Errorf("only %d bytes free, need %d", free, c.cfg.MinFreeBytes)
+		}
+		return details, nil
+	})
+}
+
+// checkScanner запускает "syft version". Результат кэшируется на ScannerCheckEvery,
+// чтобы частые пробы не порождали процессы; одновременно выполняется одна проверка.
+func (c *Checker) checkScanner(ctx context.Context) Result {
+	if res, ok := c.cachedScanner(); ok {
+		return res
+	}
+
+	select {
+	case c.scannerBusy <- struct{}{}:
+		defer func() { <-c.scannerBusy }()
+	case <-ctx.Done():
+		return Result{Status: StatusFail, Error: "scanner check is already running"}
+	}
+	// пока ждали, проверку мог выполнить другой запрос
+	if res, ok := c.cachedScanner(); ok {
+		return res
+	}
+
+	res := timed(func() (map[string]any, error) {
+		path, err := exec.LookPath(c.cfg.SyftPath)
+		if err != nil {
+			return nil, err
+		}
+		var out bytes.Buffer
+		cmd := exec.CommandContext(ctx, path, "version")
+		cmd.Stdout = &out
+		cmd.Stderr = &out
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("%s version: %v", c.cfg.SyftPath, err)
+		}
+		return map[string]any{"path": path, "version": scannerVersion(out.String())}, nil
+	})
+
+	c.mu.Lock()
+	c.scanner, c.scannerAt = res, time.Now()
+	c.mu.Unlock()
+	return res
+}
+
+func (c *Checker) cachedScanner() (Result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.scannerAt.IsZero() || time.Since(c.scannerAt) >= c.cfg.ScannerCheckEvery {
+		return Result{}, false
+	}
+	return c.scanner, true
+}
+
+// scannerVersion достаёт строку "Version: x.y.z" из вывода syft version.
+func scannerVersion(out string) string {
+	for _, line := range strings.Split(out, "\n") {
+		k, v, ok := strings.Cut(line, ":")
+		if ok && strings.TrimSpace(k) == "Version" {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
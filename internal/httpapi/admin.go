@@ -0,0 +1,184 @@
+package httpapi
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"sbom-serv/internal/auth"
+	"sbom-serv/internal/config"
+	"sbom-serv/internal/janitor"
+	"sbom-serv/internal/metrics"
+	"sbom-serv/internal/storage"
+	"sbom-serv/internal/taskstore"
+)
+
+// CancelScanHandler — отмена задачи: POST /scan/{id}/cancel.
+// Свою задачу может отменить владелец, чужую — только admin.
+func CancelScanHandler(store *taskstore.Store, onCancel func(ctx context.Context, id string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathTaskID(w, r)
+		if !ok {
+			return
+		}
+		ident, _ := auth.FromContext(r.Context())
+		tenant := auth.TenantOf(r.Context())
+
+		t, err := store.Get(r.Context(), tenant, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "task not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "failed to load task: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		owner := t.ClientID != nil && *t.ClientID == ident.ClientID
+		if !owner && !ident.Can(auth.PermScanCancelAny) {
+			http.Error(w, "forbidden: task belongs to another client", http.StatusForbidden)
+			return
+		}
+
+		err = store.Cancel(r.Context(), tenant, id, "canceled by "+ident.ClientID)
+		switch {
+		case err == nil:
+		case errors.Is(err, taskstore.ErrNotActive):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case errors.Is(err, sql.ErrNoRows):
+			http.Error(w, "task not found", http.StatusNotFound)
+			return
+		default:
+			http.Error(w, "failed to cancel task: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		metrics.TasksFailed.WithLabelValues("canceled").Inc()
+		if onCancel != nil {
+			onCancel(r.Context(), id)
+		}
+
+		storage.WriteJSON(w, map[string]any{
+			"zip_id": id,
+			"status": string(taskstore.StatusFailed),
+		})
+	}
+}
+
+// DeleteScanHandler — удаление задачи и её файлов: DELETE /scan/{id}.
+// Задачу в очереди или в работе сначала отменяют: её файлы ещё пишут
+// загрузка и воркер.
+func DeleteScanHandler(paths config.UploadPaths, store *taskstore.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathTaskID(w, r)
+		if !ok {
+			return
+		}
+
+		t, err := store.Get(r.Context(), auth.TenantOf(r.Context()), id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "task not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "failed to load task: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !t.Terminal() {
+			http.Error(w, "task is queued or running, cancel it first", http.StatusConflict)
+			return
+		}
+		if err := store.Delete(r.Context(), id); err != nil {
+			http.Error(w, "failed to delete task: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		paths.Tenant(t.Tenant).RemoveTask(id)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// JanitorRunHandler — внеочередной прогон janitor: POST /admin/janitor/run.
+// Janitor чистит задачи всех арендаторов, поэтому запускать его может только
+// администратор арендатора по умолчанию.
+func JanitorRunHandler(j *janitor.Janitor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if auth.TenantOf(r.Context()) != config.DefaultTenant {
+			http.Error(w, "forbidden: janitor runs for all tenants", http.StatusForbidden)
+			return
+		}
+		if !j.RunOnce(r.Context()) {
+			http.Error(w, "janitor is busy on another instance or database is unavailable", http.StatusConflict)
+			return
+		}
+		storage.WriteJSON(w, map[string]any{"status": "ok"})
+	}
+}
+
+// ListKeysHandler — API-ключи арендатора администратора: GET /admin/keys.
+func ListKeysHandler(keys *auth.KeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := keys.List(r.Context(), auth.TenantOf(r.Context()))
+		if err != nil {
+			http.Error(w, "failed to list keys: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		storage.WriteJSON(w, map[string]any{"keys": list})
+	}
+}
+
+type createKeyRequest struct {
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+// CreateKeyHandler — новый API-ключ в арендаторе администратора: POST /admin/keys.
+// Ключ возвращается один раз.
+func CreateKeyHandler(keys *auth.KeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req createKeyRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+			http.Error(w, "invalid json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		roles, err := auth.ParseRoles(strings.Join(req.Roles, ","))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		k, secret, err := keys.Create(r.Context(), req.Name, auth.TenantOf(r.Context()), roles)
+		if err != nil {
+			http.Error(w, "failed to create key: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"key":     k,
+			"api_key": secret,
+		})
+	}
+}
+
+// RevokeKeyHandler — отзыв API-ключа: DELETE /admin/keys/{id}.
+func RevokeKeyHandler(keys *auth.KeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathTaskID(w, r)
+		if !ok {
+			return
+		}
+		if err := keys.Revoke(r.Context(), auth.TenantOf(r.Context()), id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "key not found or already revoked", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "failed to revoke key: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
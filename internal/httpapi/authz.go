@@ -0,0 +1,73 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+
+	"sbom-serv/internal/auth"
+)
+
+// Public — маршрут без проверки прав (аутентификация для него тоже не требуется).
+const Public auth.Permission = ""
+
+// RoutePermissions — какое разрешение нужно для каждого маршрута.
+// Маршрут, которого здесь нет, зарегистрировать через Authorize нельзя.
+var RoutePermissions = map[string]auth.Permission{
+	"POST /scan":                         auth.PermScanCreate,
+	"GET /scan/info":                     auth.PermScanRead,
+	"GET /scans":                         auth.PermScanRead,
+	"GET /scans/events":                  auth.PermScanRead,
+	"GET /scans/diff":                    auth.PermScanRead,
+	"GET /packages/search":               auth.PermScanRead,
+	"GET /projects":                      auth.PermScanRead,
+	"GET /projects/{project}/versions":   auth.PermScanRead,
+	"GET /projects/{project}/sbom":       auth.PermScanRead,
+	"GET /scan/{id}/logs":                auth.PermScanRead,
+	"GET /scan/{id}/events":              auth.PermScanRead,
+	"GET /scan/{id}/webhooks":            auth.PermScanRead,
+	"GET /scan/{id}/vulnerabilities":     auth.PermScanRead,
+	"GET /scan/{id}/policy":              auth.PermScanRead,
+	"POST /scan/{id}/webhooks/redeliver": auth.PermScanCreate,
+	"POST /scan/{id}/cancel":             auth.PermScanCancel,
+	"POST /scan/{id}/pin":                auth.PermScanPin,
+	"DELETE /scan/{id}/pin":              auth.PermScanPin,
+	"GET /vulns/alerts":                  auth.PermScanRead,
+	"DELETE /scan/{id}":                  auth.PermScanDelete,
+	"POST /admin/janitor/run":            auth.PermJanitorRun,
+	"GET /admin/keys":                    auth.PermKeysManage,
+	"POST /admin/keys":                   auth.PermKeysManage,
+	"DELETE /admin/keys/{id}":            auth.PermKeysManage,
+	"GET /status":                        auth.PermStatusRead,
+	"GET /metrics":                       auth.PermMetricsRead,
+
+	// пробы оркестратора приходят без учётных данных
+	"GET /healthz": Public,
+	"GET /readyz":  Public,
+
+	"GET /openapi.yaml": Public,
+	"GET /swagger/":     Public,
+}
+
+// Authorize оборачивает обработчик проверкой разрешения из RoutePermissions.
+// Паникует, если маршрут не описан: права должны быть объявлены явно.
+func Authorize(pattern string, h http.Handler) http.Handler {
+	perm, ok := RoutePermissions[pattern]
+	if !ok {
+		panic(fmt.Sprintf("httpapi: no permission declared for route %q", pattern))
+	}
+	if perm == Public {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := auth.FromContext(r.Context())
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !id.Can(perm) {
+			http.Error(w, "forbidden: missing permission "+string(perm), http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
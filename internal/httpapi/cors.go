@@ -0,0 +1,221 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSPolicy — какие сайты могут обращаться к API из браузера.
+// Origin задаётся точно ("https://ui.example.com"), маской поддоменов
+// ("https://*.example.com") или "*" для всех. Пустой список выключает CORS.
+type CORSPolicy struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+type originPattern struct {
+	scheme string
+	host   string // для маски — суффикс вида ".example.com"
+	port   string
+	sub    bool
+}
+
+type cors struct {
+	anyOrigin   bool
+	origins     []originPattern
+	methods     map[string]bool
+	headers     map[string]bool
+	anyHeader   bool
+	allowMethod string
+	allowHeader string
+	expose      string
+	credentials bool
+	maxAge      string
+}
+
+// CORS применяет policy к запросам с заголовком Origin. Preflight-запрос
+// с неразрешённым origin, методом или заголовком получает 403.
+func CORS(next http.Handler, policy CORSPolicy) http.Handler {
+	if len(policy.AllowedOrigins) == 0 {
+		return next
+	}
+	c := compileCORS(policy)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if !c.anyOrigin || c.credentials {
+			// ответ зависит от Origin — кэши не должны отдавать его другим сайтам
+			w.Header().Add("Vary", "Origin")
+		}
+		if preflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		allowed := c.allowOrigin(origin)
+
+		if preflight {
+			if !allowed ||
+				!c.methods[strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))] ||
+				!c.allowHeaders(r.Header.Values("Access-Control-Request-Headers")) {
+				http.Error(w, "cors preflight rejected", http.StatusForbidden)
+				return
+			}
+			c.writeOrigin(w, origin)
+			w.Header().Set("Access-Control-Allow-Methods", c.allowMethod)
+			if req := r.Header.Get("Access-Control-Request-Headers"); c.anyHeader && req != "" {
+				w.Header().Set("Access-Control-Allow-Headers", req)
+			} else if c.allowHeader != "" {
+				w.Header().Set("Access-Control-Allow-Headers", c.allowHeader)
+			}
+			if c.maxAge != "" {
+				w.Header().Set("Access-Control-Max-Age", c.maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if allowed {
+			c.writeOrigin(w, origin)
+			if c.expose != "" {
+				w.Header().Set("Access-Control-Expose-Headers", c.expose)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func compileCORS(p CORSPolicy) *cors {
+	c := &cors{
+		methods:     map[string]bool{},
+		headers:     map[string]bool{},
+		credentials: p.AllowCredentials,
+		expose:      strings.Join(p.ExposedHeaders, ", "),
+	}
+	for _, o := range p.AllowedOrigins {
+		if o == "*" {
+			c.anyOrigin = true
+			continue
+		}
+		if op, ok := parseOriginPattern(o); ok {
+			c.origins = append(c.origins, op)
+		}
+	}
+
+	methods := make([]string, 0, len(p.AllowedMethods))
+	for _, m := range p.AllowedMethods {
+		m = strings.ToUpper(strings.TrimSpace(m))
+		if m != "" && !c.methods[m] {
+			c.methods[m] = true
+			methods = append(methods, m)
+		}
+	}
+	c.allowMethod = strings.Join(methods, ", ")
+
+	headers := make([]string, 0, len(p.AllowedHeaders))
+	for _, h := range p.AllowedHeaders {
+		h = strings.TrimSpace(h)
+		if h == "*" {
+			c.anyHeader = true
+			continue
+		}
+		if h != "" {
+			c.headers[strings.ToLower(h)] = true
+			headers = append(headers, http.CanonicalHeaderKey(h))
+		}
+	}
+	c.allowHeader = strings.Join(headers, ", ")
+
+	if p.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(p.MaxAge.Seconds()))
+	}
+	return c
+}
+
+// parseOriginPattern разбирает "scheme://host[:port]", host может начинаться с "*.".
+func parseOriginPattern(s string) (originPattern, bool) {
+	scheme, rest, ok := strings.Cut(strings.ToLower(strings.TrimSpace(s)), "://")
+	if !ok || scheme == "" || rest == "" || strings.ContainsAny(rest, "/?#@") {
+		return originPattern{}, false
+	}
+	op := originPattern{scheme: scheme}
+	host := rest
+	if h, port, ok := strings.Cut(rest, ":"); ok {
+		host, op.port = h, port
+	}
+	if suffix, ok := strings.CutPrefix(host, "*."); ok {
+		op.sub = true
+		host = "." + suffix
+	}
+	if host == "" || host == "." || strings.Contains(host, "*") {
+		return originPattern{}, false
+	}
+	op.host = host
+	return op, true
+}
+
+func (c *cors) allowOrigin(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+	u, err := url.Parse(strings.ToLower(origin))
+	if err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+		return false
+	}
+	host, port := u.Hostname(), u.Port()
+	for _, op := range c.origins {
+		if op.scheme != u.Scheme || op.port != port {
+			continue
+		}
+		if op.sub {
+			// маска не совпадает с самим доменом: *.example.com не пускает example.com
+			if strings.HasSuffix(host, op.host) && len(host) > len(op.host) {
+				return true
+			}
+			continue
+		}
+		if host == op.host {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *cors) allowHeaders(values []string) bool {
+	if c.anyHeader {
+		return true
+	}
+	for _, v := range values {
+		for _, h := range strings.Split(v, ",") {
+			h = strings.ToLower(strings.TrimSpace(h))
+			if h != "" && !c.headers[h] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (c *cors) writeOrigin(w http.ResponseWriter, origin string) {
+	if c.anyOrigin && !c.credentials {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if c.credentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
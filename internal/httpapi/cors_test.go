@@ -0,0 +1,113 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	h := CORS(next, CORSPolicy{
+		AllowedOrigins:   []string{"https://*.example.com", "https://ui.corp.local:8443"},
+		AllowedMethods:   []string{"GET", "post"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+
+	cases := []struct {
+		name      string
+		origin    string
+		preflight bool
+		method    string // Access-Control-Request-Method
+		headers   string // Access-Control-Request-Headers
+		status    int
+		allowed   bool
+	}{
+		{name: "subdomain", origin: "https://a.example.com", status: 200, allowed: true},
+		{name: "nested subdomain", origin: "https://a.b.example.com", status: 200, allowed: true},
+		{name: "mask does not match apex", origin: "https://example.com", status: 200},
+		{name: "suffix without dot", origin: "https://evil-example.com", status: 200},
+		{name: "mask as prefix", origin: "https://a.example.com.evil.com", status: 200},
+		{name: "other scheme", origin: "http://a.example.com", status: 200},
+		{name: "other port", origin: "https://a.example.com:8443", status: 200},
+		{name: "exact with port", origin: "https://ui.corp.local:8443", status: 200, allowed: true},
+		{name: "exact without port", origin: "https://ui.corp.local", status: 200},
+		{name: "no origin", status: 200},
+
+		{name: "preflight allowed", origin: "https://a.example.com", preflight: true,
+			method: "POST", headers: "authorization, content-type", status: 204, allowed: true},
+		{name: "preflight origin", origin: "https://evil-example.com", preflight: true,
+			method: "GET", status: 403},
+		{name: "preflight apex", origin: "https://example.com", preflight: true,
+			method: "GET", status: 403},
+		{name: "preflight method", origin: "https://a.example.com", preflight: true,
+			method: "DELETE", status: 403},
+		{name: "preflight header", origin: "https://a.example.com", preflight: true,
+			method: "GET", headers: "Authorization, X-Evil", status: 403},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			method := http.MethodGet
+			if tc.preflight {
+				method = http.MethodOptions
+			}
+			r := httptest.NewRequest(method, "/scan/info", nil)
+			if tc.origin != "" {
+				r.Header.Set("Origin", tc.origin)
+			}
+			if tc.method != "" {
+				r.Header.Set("Access-Control-Request-Method", tc.method)
+			}
+			if tc.headers != "" {
+				r.Header.Set("Access-Control-Request-Headers", tc.headers)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tc.status {
+				t.Errorf("status = %d, want %d", w.Code, tc.status)
+			}
+			// и разрешённые, и отклонённые ответы зависят от Origin
+			if !slices.Contains(w.Header().Values("Vary"), "Origin") {
+				t.Errorf("Vary = %q, want Origin", w.Header().Values("Vary"))
+			}
+			acao := w.Header().Get("Access-Control-Allow-Origin")
+			if tc.allowed {
+				if acao != tc.origin {
+					t.Errorf("Access-Control-Allow-Origin = %q, want %q", acao, tc.origin)
+				}
+				if w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+					t.Error("missing Access-Control-Allow-Credentials")
+				}
+			} else if acao != "" {
+				t.Errorf("Access-Control-Allow-Origin = %q, want none", acao)
+			}
+			if tc.preflight && tc.allowed {
+				if got := w.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST" {
+					t.Errorf("Access-Control-Allow-Methods = %q", got)
+				}
+				if got := w.Header().Get("Access-Control-Max-Age"); got != "600" {
+					t.Errorf("Access-Control-Max-Age = %q", got)
+				}
+			}
+		})
+	}
+}
+
+func TestParseOriginPattern(t *testing.T) {
+	for _, s := range []string{"example.com", "https://", "https://*.", "https://a.*.com", "https://x.com/path", "https://u@x.com"} {
+		if _, ok := parseOriginPattern(s); ok {
+			t.Errorf("parseOriginPattern(%q) accepted", s)
+		}
+	}
+	op, ok := parseOriginPattern(" HTTPS://*.Example.com:8443 ")
+	if !ok || op != (originPattern{scheme: "https", host: ".example.com", port: "8443", sub: true}) {
+		t.Errorf("parseOriginPattern = %+v, %v", op, ok)
+	}
+}
@@ -0,0 +1,81 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"sbom-serv/internal/auth"
+	"sbom-serv/internal/config"
+	"sbom-serv/internal/health"
+	"sbom-serv/internal/storage"
+	"sbom-serv/internal/taskstore"
+	"sbom-serv/internal/worker"
+)
+
+// HealthzHandler — процесс жив: GET /healthz. Зависимости не проверяются,
+// чтобы недоступная БД не приводила к перезапуску всех инстансов.
+func HealthzHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		storage.WriteJSON(w, map[string]any{"status": health.StatusOK})
+	}
+}
+
+// ReadyzHandler — инстанс готов принимать задачи: GET /readyz.
+// 503, если не прошла хотя бы одна проверка. Маршрут открыт без
+// аутентификации, поэтому подробности проверок есть только в /status.
+func ReadyzHandler(checker *health.Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rep := checker.Check(r.Context())
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if !rep.OK() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(rep.Summary())
+	}
+}
+
+// StatusHandler — подробное состояние инстанса для администратора: GET /status.
+// Очередь — по арендатору вызывающего; по всем арендаторам — только для
+// арендатора по умолчанию.
+func StatusHandler(checker *health.Checker, store *taskstore.Store, wk *worker.Worker) http.HandlerFunc {
+	started := time.Now()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		rep := checker.Check(r.Context())
+
+		resp := map[string]any{
+			"status":         rep.Status,
+			"started_at":     started.UTC(),
+			"uptime_seconds": int64(time.Since(started).Seconds()),
+			"checks":         rep.Checks,
+			"worker": map[string]any{
+				"busy":     wk.Busy(),
+				"capacity": wk.Capacity(),
+			},
+		}
+
+		tenant := auth.TenantOf(r.Context())
+		if tenant == config.DefaultTenant {
+			tenant = taskstore.AnyTenant
+		}
+		qs, err := store.QueueStats(r.Context(), tenant)
+		if err != nil {
+			resp["queue_error"] = err.Error()
+		} else {
+			queue := map[string]any{"uploading": qs.Uploading}
+			for status, n := range qs.Counts {
+				queue[string(status)] = n
+			}
+			if qs.OldestQueued != nil {
+				queue["oldest_queued_seconds"] = int64(time.Since(*qs.OldestQueued).Seconds())
+			}
+			resp["queue"] = queue
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		storage.WriteJSON(w, resp)
+	}
+}
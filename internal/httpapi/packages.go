@@ -0,0 +1,57 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+
+	"sbom-serv/internal/auth"
+	"sbom-serv/internal/pkgindex"
+	"sbom-serv/internal/storage"
+	"sbom-serv/internal/vulndb"
+)
+
+// PackageSearchHandler — в результатах каких задач есть пакет:
+// GET /packages/search?name=&purl=&cpe=&type=&version=&project=&limit=
+func PackageSearchHandler(packages *pkgindex.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		query := pkgindex.Query{
+			Tenant:   auth.TenantOf(r.Context()),
+			Name:     q.Get("name"),
+			PURL:     q.Get("purl"),
+			CPE:      q.Get("cpe"),
+			Type:     q.Get("type"),
+			Versions: q.Get("version"),
+			Project:  q.Get("project"),
+			Limit:    100,
+		}
+		if query.Name == "" && query.PURL == "" && query.CPE == "" {
+			http.Error(w, pkgindex.ErrEmptyQuery.Error(), http.StatusBadRequest)
+			return
+		}
+		if query.Versions != "" {
+			if _, err := vulndb.ParseVersionRange(query.Versions); err != nil {
+				http.Error(w, "invalid version: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > 1000 {
+				http.Error(w, "invalid limit: want 1..1000", http.StatusBadRequest)
+				return
+			}
+			query.Limit = n
+		}
+
+		matches, truncated, err := packages.Search(r.Context(), query)
+		if err != nil {
+			http.Error(w, "failed to search packages: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		storage.WriteJSON(w, map[string]any{
+			"tasks":     matches,
+			"truncated": truncated,
+		})
+	}
+}
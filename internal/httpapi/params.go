@@ -0,0 +1,40 @@
+package httpapi
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"sbom-serv/internal/auth"
+	"sbom-serv/internal/taskstore"
+)
+
+// pathTaskID достаёт {id} из пути и проверяет, что это UUID (задачи или ключа).
+func pathTaskID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, "missing id", http.StatusBadRequest)
+		return "", false
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return "", false
+	}
+	return id, true
+}
+
+// taskVisible проверяет, что задача существует и принадлежит арендатору запроса.
+func taskVisible(w http.ResponseWriter, r *http.Request, store *taskstore.Store, id string) bool {
+	_, err := store.Get(r.Context(), auth.TenantOf(r.Context()), id)
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "task not found", http.StatusNotFound)
+		return false
+	}
+	http.Error(w, "failed to load task: "+err.Error(), http.StatusInternalServerError)
+	return false
+}
@@ -0,0 +1,87 @@
+package httpapi
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"sbom-serv/internal/auth"
+	"sbom-serv/internal/storage"
+	"sbom-serv/internal/taskstore"
+	"sbom-serv/internal/vulnwatch"
+)
+
+// PinScanHandler — закрепление SBOM выпущенной версии: POST /scan/{id}/pin
+// (pinned=true) и DELETE /scan/{id}/pin (pinned=false). Закреплённую задачу
+// janitor не удаляет, а её SBOM сопоставляется заново при обновлении базы
+// уязвимостей.
+func PinScanHandler(store *taskstore.Store, pinned bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathTaskID(w, r)
+		if !ok {
+			return
+		}
+
+		t, err := store.Pin(r.Context(), auth.TenantOf(r.Context()), id, pinned)
+		switch {
+		case err == nil:
+		case errors.Is(err, sql.ErrNoRows):
+			http.Error(w, "task not found", http.StatusNotFound)
+			return
+		case errors.Is(err, taskstore.ErrNotDone):
+			http.Error(w, "only done tasks can be pinned", http.StatusConflict)
+			return
+		default:
+			http.Error(w, "failed to pin task: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		resp := map[string]any{
+			"zip_id": id,
+			"pinned": t.PinnedAt != nil,
+		}
+		if t.PinnedAt != nil {
+			resp["pinned_at"] = *t.PinnedAt
+		}
+		storage.WriteJSON(w, resp)
+	}
+}
+
+// VulnAlertsHandler — новые уязвимости в закреплённых задачах арендатора:
+// GET /vulns/alerts?project=&zip_id=&since=&limit=
+func VulnAlertsHandler(watcher *vulnwatch.Watcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		f := vulnwatch.AlertFilter{
+			Tenant:  auth.TenantOf(r.Context()),
+			Project: q.Get("project"),
+			TaskID:  q.Get("zip_id"),
+			Limit:   100,
+		}
+		if v := q.Get("since"); v != "" {
+			since, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "invalid since: want RFC 3339 time", http.StatusBadRequest)
+				return
+			}
+			f.Since = since
+		}
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			f.Limit = n
+		}
+
+		alerts, err := watcher.Alerts(r.Context(), f)
+		if err != nil {
+			http.Error(w, "failed to list alerts: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		storage.WriteJSON(w, map[string]any{"alerts": alerts})
+	}
+}
@@ -0,0 +1,62 @@
+package httpapi
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"sbom-serv/internal/auth"
+	"sbom-serv/internal/policy"
+	"sbom-serv/internal/storage"
+	"sbom-serv/internal/taskstore"
+)
+
+// ScanPolicyHandler — результат проверки SBOM задачи по политике проекта:
+// GET /scan/{id}/policy.
+func ScanPolicyHandler(store *taskstore.Store, policies *policy.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathTaskID(w, r)
+		if !ok {
+			return
+		}
+
+		t, err := store.Get(r.Context(), auth.TenantOf(r.Context()), id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "task not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "failed to load task: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if t.Status != taskstore.StatusDone {
+			http.Error(w, "task is not finished", http.StatusConflict)
+			return
+		}
+
+		rep, evaluatedAt, err := policies.TaskReport(r.Context(), id)
+		if err != nil {
+			http.Error(w, "failed to load policy report: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if rep == nil {
+			http.Error(w, "task was not evaluated: no policy for its project", http.StatusConflict)
+			return
+		}
+
+		resp := map[string]any{
+			"zip_id":       id,
+			"evaluated_at": evaluatedAt,
+			"project":      rep.Project,
+			"verdict":      rep.Verdict,
+			"failed":       rep.Failed,
+		}
+		if rep.Licenses != nil {
+			resp["licenses"] = rep.Licenses
+		}
+		if rep.Checks != nil {
+			resp["checks"] = rep.Checks
+		}
+		storage.WriteJSON(w, resp)
+	}
+}
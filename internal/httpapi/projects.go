@@ -0,0 +1,89 @@
+package httpapi
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"os"
+
+	"sbom-serv/internal/auth"
+	"sbom-serv/internal/config"
+	"sbom-serv/internal/project"
+	"sbom-serv/internal/storage"
+)
+
+// Заголовки ответа GET /projects/{project}/sbom
+const (
+	zipIDHeader          = "X-Zip-ID"
+	projectVersionHeader = "X-Project-Version"
+)
+
+// ProjectListHandler — проекты арендатора: GET /projects.
+func ProjectListHandler(projects *project.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := projects.List(r.Context(), auth.TenantOf(r.Context()))
+		if err != nil {
+			http.Error(w, "failed to list projects: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		storage.WriteJSON(w, map[string]any{"projects": list})
+	}
+}
+
+// ProjectVersionsHandler — история версий проекта: GET /projects/{project}/versions.
+func ProjectVersionsHandler(projects *project.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("project")
+		versions, err := projects.Versions(r.Context(), auth.TenantOf(r.Context()), name)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "project not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "failed to list versions: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		storage.WriteJSON(w, map[string]any{
+			"project":  name,
+			"versions": versions,
+		})
+	}
+}
+
+// ProjectSBOMHandler — текущий SBOM проекта: GET /projects/{project}/sbom?version=.
+// Без version — результат последней успешной задачи проекта. Тело — сам SBOM,
+// как в /scan/info; задача и версия отдаются заголовками.
+func ProjectSBOMHandler(paths config.UploadPaths, projects *project.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant := auth.TenantOf(r.Context())
+		name := r.PathValue("project")
+		version := r.URL.Query().Get("version")
+
+		id, taskVersion, err := projects.Current(r.Context(), tenant, name, version)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows) && version != "":
+				http.Error(w, "project version not found", http.StatusNotFound)
+			case errors.Is(err, sql.ErrNoRows):
+				http.Error(w, "project has no completed tasks", http.StatusNotFound)
+			case errors.Is(err, project.ErrNoSBOM):
+				http.Error(w, "SBOM of this version was deleted", http.StatusNotFound)
+			default:
+				http.Error(w, "failed to load project: "+err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		b, err := os.ReadFile(paths.Tenant(tenant).ResultPath(id))
+		if err != nil {
+			http.Error(w, "result not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(zipIDHeader, id)
+		if taskVersion != "" {
+			w.Header().Set(projectVersionHeader, taskVersion)
+		}
+		_, _ = w.Write(b)
+	}
+}
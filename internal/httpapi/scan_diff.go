@@ -0,0 +1,108 @@
+package httpapi
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/google/uuid"
+
+	"sbom-serv/internal/auth"
+	"sbom-serv/internal/config"
+	"sbom-serv/internal/sbom"
+	"sbom-serv/internal/sbomdiff"
+	"sbom-serv/internal/storage"
+	"sbom-serv/internal/taskstore"
+	"sbom-serv/internal/vulndb"
+)
+
+// ScanDiffHandler — разница между результатами двух задач:
+// GET /scans/diff?from=&to=&format=json|markdown.
+func ScanDiffHandler(paths config.UploadPaths, store *taskstore.Store, vulns *vulndb.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		format := q.Get("format")
+		switch format {
+		case "":
+			format = "json"
+		case "json", "markdown":
+		default:
+			http.Error(w, "invalid format: want json or markdown", http.StatusBadRequest)
+			return
+		}
+		fromID, toID := q.Get("from"), q.Get("to")
+		for _, id := range []string{fromID, toID} {
+			if _, err := uuid.Parse(id); err != nil {
+				http.Error(w, "from and to must be task ids", http.StatusBadRequest)
+				return
+			}
+		}
+
+		from, fromDoc, ok := loadResult(w, r, paths, store, fromID)
+		if !ok {
+			return
+		}
+		to, toDoc, ok := loadResult(w, r, paths, store, toID)
+		if !ok {
+			return
+		}
+
+		d := sbomdiff.Compare(fromDoc, toDoc)
+		d.From.ZipID, d.To.ZipID = from.ID, to.ID
+		if from.Project != nil {
+			d.From.Project = *from.Project
+		}
+		if to.Project != nil {
+			d.To.Project = *to.Project
+		}
+
+		fromVulns, fromMatch, err := vulns.TaskFindings(r.Context(), from.ID)
+		if err != nil {
+			http.Error(w, "failed to load vulnerabilities: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		toVulns, toMatch, err := vulns.TaskFindings(r.Context(), to.ID)
+		if err != nil {
+			http.Error(w, "failed to load vulnerabilities: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if fromMatch != nil && toMatch != nil {
+			d.CompareVulns(fromVulns, toVulns)
+		}
+
+		if format == "markdown" {
+			w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+			_, _ = w.Write([]byte(d.Markdown()))
+			return
+		}
+		storage.WriteJSON(w, d)
+	}
+}
+
+// loadResult читает SBOM завершённой задачи арендатора запроса.
+func loadResult(w http.ResponseWriter, r *http.Request, paths config.UploadPaths, store *taskstore.Store, id string) (taskstore.Task, *sbom.Document, bool) {
+	t, err := store.Get(r.Context(), auth.TenantOf(r.Context()), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "task not found: "+id, http.StatusNotFound)
+			return t, nil, false
+		}
+		http.Error(w, "failed to load task: "+err.Error(), http.StatusInternalServerError)
+		return t, nil, false
+	}
+	if t.Status != taskstore.StatusDone {
+		http.Error(w, "task is not finished: "+id, http.StatusConflict)
+		return t, nil, false
+	}
+	doc, err := sbom.ReadFile(paths.Tenant(t.Tenant).ResultPath(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			http.Error(w, "result not found: "+id, http.StatusNotFound)
+			return t, nil, false
+		}
+		http.Error(w, "failed to read result: "+err.Error(), http.StatusInternalServerError)
+		return t, nil, false
+	}
+	return t, doc, true
+}
@@ -0,0 +1,200 @@
+package httpapi
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"sbom-serv/internal/auth"
+	"sbom-serv/internal/events"
+	"sbom-serv/internal/taskstore"
+)
+
+// Как часто отправлять комментарий-пинг, чтобы прокси не рвали соединение
+const sseHeartbeat = 15 * time.Second
+
+// ScanEventsHandler — SSE-поток изменений одной задачи: GET /scan/{id}/events.
+// Поток закрывается, когда задача переходит в done или failed или удаляется.
+func ScanEventsHandler(store *taskstore.Store, hub *events.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathTaskID(w, r)
+		if !ok {
+			return
+		}
+
+		// подписываемся до чтения задачи, чтобы не пропустить переход между ними
+		sub := hub.Subscribe(func(ev events.Event) bool { return ev.ID == id })
+		defer hub.Unsubscribe(sub)
+
+		tenant := auth.TenantOf(r.Context())
+		t, err := store.Get(r.Context(), tenant, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "task not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "failed to load task: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		sse, ok := newSSEWriter(w)
+		if !ok {
+			return
+		}
+
+		last := events.FromTask(t)
+		if err := sse.Send(last); err != nil || last.Terminal() {
+			return
+		}
+
+		heartbeat := time.NewTicker(sseHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-hub.Done():
+				return
+			case <-heartbeat.C:
+				if err := sse.Ping(); err != nil {
+					return
+				}
+			case ev := <-sub.C:
+				if ev.Resync {
+					t, err := store.Get(r.Context(), tenant, id)
+					switch {
+					case errors.Is(err, sql.ErrNoRows):
+						// уведомление об удалении могло потеряться
+						ev = events.Deleted(last)
+					case err != nil:
+						return
+					default:
+						ev = events.FromTask(t)
+					}
+				}
+				if sameEvent(last, ev) {
+					continue
+				}
+				last = ev
+				if err := sse.Send(ev); err != nil || ev.Terminal() {
+					return
+				}
+			}
+		}
+	}
+}
+
+// ProjectEventsHandler — SSE-поток изменений всех задач проекта:
+// GET /scans/events?project=... Сначала отправляет активные задачи проекта,
+// затем их изменения и новые задачи. Поток не завершается сам.
+func ProjectEventsHandler(store *taskstore.Store, hub *events.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		project := r.URL.Query().Get("project")
+		if project == "" {
+			http.Error(w, "missing project", http.StatusBadRequest)
+			return
+		}
+
+		tenant := auth.TenantOf(r.Context())
+		sub := hub.Subscribe(func(ev events.Event) bool {
+			return ev.Tenant == tenant && ev.Project != nil && *ev.Project == project
+		})
+		defer hub.Unsubscribe(sub)
+
+		sendActive := func(sse *sseWriter) error {
+			tasks, err := store.List(r.Context(), taskstore.ListFilter{Tenant: tenant, Project: project, Active: true})
+			if err != nil {
+				return err
+			}
+			for _, t := range tasks {
+				if err := sse.Send(events.FromTask(t)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		sse, ok := newSSEWriter(w)
+		if !ok {
+			return
+		}
+		if err := sendActive(sse); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(sseHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-hub.Done():
+				return
+			case <-heartbeat.C:
+				if err := sse.Ping(); err != nil {
+					return
+				}
+			case ev := <-sub.C:
+				var err error
+				if ev.Resync {
+					err = sendActive(sse)
+				} else {
+					err = sse.Send(ev)
+				}
+				if err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// sameEvent отсекает повторы после Resync, когда состояние не изменилось.
+func sameEvent(a, b events.Event) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return string(ja) == string(jb)
+}
+
+type sseWriter struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// newSSEWriter отправляет заголовки потока. false — соединение не поддерживает
+// Flush или уже закрыто, продолжать смысла нет.
+func newSSEWriter(w http.ResponseWriter) (*sseWriter, bool) {
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return nil, false
+	}
+	return &sseWriter{w: w, rc: rc}, true
+}
+
+func (s *sseWriter) Send(ev events.Event) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "event: task\ndata: %s\n\n", b); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+func (s *sseWriter) Ping() error {
+	if _, err := fmt.Fprint(s.w, ": ping\n\n"); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
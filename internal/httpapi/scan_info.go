@@ -1,25 +1,53 @@
 package httpapi
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"os"
-	"path/filepath"
+	"strconv"
+	"time"
 
+	"sbom-serv/internal/auth"
 	"sbom-serv/internal/config"
+	"sbom-serv/internal/events"
+	"sbom-serv/internal/policy"
 	"sbom-serv/internal/taskstore"
 )
 
-func ScanInfoHandler(paths config.UploadPaths, store *taskstore.Store) http.HandlerFunc {
+// Максимальное время ожидания для ?wait=
+const maxInfoWait = 60 * time.Second
+
+// Итог проверки по политике для готовой задачи (pass, review, failed); он же —
+// в поле policy тела ответа, если заголовок не виден клиенту (CORS)
+const policyVerdictHeader = "X-Policy-Verdict"
+
+func ScanInfoHandler(paths config.UploadPaths, store *taskstore.Store, hub *events.Hub, policies *policy.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.URL.Query().Get("id")
 		if id == "" {
 			http.Error(w, "missing id", http.StatusBadRequest)
 			return
 		}
+		wait, err := parseWait(r.URL.Query().Get("wait"))
+		if err != nil {
+			http.Error(w, "invalid wait: "+err.Error(), http.StatusBadRequest)
+			return
+		}
 
-		t, err := store.Get(r.Context(), id)
+		tenant := auth.TenantOf(r.Context())
+
+		var t taskstore.Task
+		if wait > 0 {
+			t, err = waitForTask(r.Context(), store, hub, tenant, id, wait)
+		} else {
+			t, err = store.Get(r.Context(), tenant, id)
+		}
+		if r.Context().Err() != nil {
+			return
+		}
 		if err != nil {
 			if errors.Is(err, os.ErrNotExist) {
 				http.Error(w, "task not found", http.StatusNotFound)
@@ -33,30 +61,58 @@ func ScanInfoHandler(paths config.UploadPaths, store *taskstore.Store) http.Hand
 		case taskstore.StatusQueued, taskstore.StatusRunning:
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusAccepted)
-			_ = json.NewEncoder(w).Encode(map[string]any{
+			resp := map[string]any{
 				"zip_id": id,
 				"status": string(t.Status),
 				"ts":     t.Timestamp,
-			})
+			}
+			if t.Stage != nil {
+				resp["stage"] = string(*t.Stage)
+			}
+			if t.Progress != nil {
+				resp["progress"] = *t.Progress
+			}
+			if t.Packages != nil {
+				resp["packages"] = *t.Packages
+			}
+			_ = json.NewEncoder(w).Encode(resp)
 			return
 
 		case taskstore.StatusFailed:
 			w.Header().Set("Content-Type", "application/json")
-			_ = json.NewEncoder(w).Encode(map[string]any{
+			resp := map[string]any{
 				"zip_id": id,
 				"status": "failed",
 				"error":  t.Error,
 				"ts":     t.Timestamp,
-			})
+			}
+			if t.Stage != nil {
+				resp["stage"] = string(*t.Stage)
+			}
+			_ = json.NewEncoder(w).Encode(resp)
 			return
 
 		case taskstore.StatusDone:
-			resPath := filepath.Join(paths.Results, "result-"+id+".json")
+			resPath := paths.Tenant(t.Tenant).ResultPath(id)
 			b, err := os.ReadFile(resPath)
 			if err != nil {
 				http.Error(w, "result not found", http.StatusNotFound)
 				return
 			}
+			if t.PolicyVerdict != nil {
+				rep, _, err := policies.TaskReport(r.Context(), id)
+				if err != nil {
+					http.Error(w, "failed to load policy report: "+err.Error(), http.StatusInternalServerError)
+					return
+				}
+				if rep != nil {
+					if b, err = withPolicy(b, rep.Summary()); err != nil {
+						http.Error(w, "invalid result: "+err.Error(), http.StatusInternalServerError)
+						return
+					}
+				}
+				w.Header().Set(policyVerdictHeader, *t.PolicyVerdict)
+			}
 			w.Header().Set("Content-Type", "application/json")
 			_, _ = w.Write(b)
 			return
@@ -67,3 +123,82 @@ func ScanInfoHandler(paths config.UploadPaths, store *taskstore.Store) http.Hand
 		}
 	}
 }
+
+// withPolicy добавляет в начало SBOM поле policy: {"policy":{...}, <поля SBOM>}.
+// SBOM не разбирается целиком — результаты бывают по сотне мегабайт.
+func withPolicy(doc []byte, sum policy.Summary) ([]byte, error) {
+	const ws = " \t\r\n"
+	body := bytes.TrimLeft(doc, ws)
+	if len(body) == 0 || body[0] != '{' {
+		return nil, errors.New("SBOM is not a JSON object")
+	}
+	field, err := json.Marshal(sum)
+	if err != nil {
+		return nil, err
+	}
+	rest := body[1:]
+	out := make([]byte, 0, len(doc)+len(field)+16)
+	out = append(out, `{"policy":`...)
+	out = append(out, field...)
+	if r := bytes.TrimLeft(rest, ws); len(r) > 0 && r[0] != '}' {
+		out = append(out, ',')
+	}
+	return append(out, rest...), nil
+}
+
+// parseWait принимает длительность ("30s", "1m") или число секунд.
+func parseWait(raw string) (time.Duration, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		sec, convErr := strconv.Atoi(raw)
+		if convErr != nil {
+			return 0, err
+		}
+		d = time.Duration(sec) * time.Second
+	}
+	if d < 0 {
+		return 0, errors.New("must not be negative")
+	}
+	if d > maxInfoWait {
+		d = maxInfoWait
+	}
+	return d, nil
+}
+
+// waitForTask ждёт, пока задача выйдет из queued/running, но не дольше wait.
+// БД не опрашивается в цикле: ожидание будят уведомления из events.Hub.
+func waitForTask(ctx context.Context, store *taskstore.Store, hub *events.Hub, tenant, id string, wait time.Duration) (taskstore.Task, error) {
+	sub := hub.Subscribe(func(ev events.Event) bool { return ev.ID == id })
+	defer hub.Unsubscribe(sub)
+
+	t, err := store.Get(ctx, tenant, id)
+	if err != nil || t.Terminal() {
+		return t, err
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return t, ctx.Err()
+		case <-hub.Done():
+			return t, nil
+		case <-timer.C:
+			// за время ожидания могли обновиться этап и прогресс
+			return store.Get(ctx, tenant, id)
+		case ev := <-sub.C:
+			if !ev.Resync && !ev.Terminal() {
+				continue
+			}
+			t, err = store.Get(ctx, tenant, id)
+			if err != nil || t.Terminal() {
+				return t, err
+			}
+		}
+	}
+}
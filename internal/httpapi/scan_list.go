@@ -0,0 +1,77 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+
+	"sbom-serv/internal/auth"
+	"sbom-serv/internal/storage"
+	"sbom-serv/internal/taskstore"
+)
+
+// ScanListHandler — задачи текущего клиента: GET /scans?project=&active=&pinned=&limit=
+func ScanListHandler(store *taskstore.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ident, ok := auth.FromContext(r.Context())
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		q := r.URL.Query()
+		f := taskstore.ListFilter{
+			Tenant:   auth.TenantOf(r.Context()),
+			ClientID: ident.ClientID,
+			Project:  q.Get("project"),
+			Active:   q.Get("active") == "true",
+			Pinned:   q.Get("pinned") == "true",
+			Limit:    100,
+		}
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			f.Limit = n
+		}
+
+		tasks, err := store.List(r.Context(), f)
+		if err != nil {
+			http.Error(w, "failed to list tasks: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		items := make([]map[string]any, 0, len(tasks))
+		for _, t := range tasks {
+			item := map[string]any{
+				"zip_id": t.ID,
+				"status": string(t.Status),
+				"ts":     t.Timestamp,
+			}
+			if t.Stage != nil {
+				item["stage"] = string(*t.Stage)
+			}
+			if t.Project != nil {
+				item["project"] = *t.Project
+			}
+			if t.ProjectVersion != nil {
+				item["version"] = *t.ProjectVersion
+			}
+			if t.Packages != nil {
+				item["packages"] = *t.Packages
+			}
+			if t.Error != nil {
+				item["error"] = *t.Error
+			}
+			if t.PinnedAt != nil {
+				item["pinned_at"] = *t.PinnedAt
+			}
+			if t.Status == taskstore.StatusDone && t.PolicyVerdict != nil {
+				item["policy"] = *t.PolicyVerdict
+			}
+			items = append(items, item)
+		}
+		storage.WriteJSON(w, map[string]any{"tasks": items})
+	}
+}
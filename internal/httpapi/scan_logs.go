@@ -0,0 +1,52 @@
+package httpapi
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"os"
+
+	"sbom-serv/internal/auth"
+	"sbom-serv/internal/config"
+	"sbom-serv/internal/taskstore"
+)
+
+// ScanLogsHandler отдаёт полный лог сканера по задаче: GET /scan/{id}/logs
+func ScanLogsHandler(paths config.UploadPaths, store *taskstore.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathTaskID(w, r)
+		if !ok {
+			return
+		}
+
+		t, err := store.Get(r.Context(), auth.TenantOf(r.Context()), id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "task not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "failed to load task: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		f, err := os.Open(paths.Tenant(t.Tenant).LogPath(id))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				http.Error(w, "log not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "failed to open log", http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+
+		info, err := f.Stat()
+		if err != nil {
+			http.Error(w, "failed to open log", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.ServeContent(w, r, "", info.ModTime(), f)
+	}
+}
@@ -0,0 +1,78 @@
+package httpapi
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"sbom-serv/internal/auth"
+	"sbom-serv/internal/storage"
+	"sbom-serv/internal/taskstore"
+	"sbom-serv/internal/vulndb"
+)
+
+// ScanVulnsHandler — уязвимости, найденные в SBOM задачи: GET /scan/{id}/vulnerabilities.
+// ?min_severity=high оставляет только уязвимости этого уровня и выше.
+func ScanVulnsHandler(store *taskstore.Store, vulns *vulndb.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathTaskID(w, r)
+		if !ok {
+			return
+		}
+		minRank := 0
+		if raw := r.URL.Query().Get("min_severity"); raw != "" {
+			sev, ok := vulndb.ParseSeverity(raw)
+			if !ok {
+				http.Error(w, "invalid min_severity: want low, medium, high or critical", http.StatusBadRequest)
+				return
+			}
+			minRank = vulndb.SeverityRank(sev)
+		}
+
+		t, err := store.Get(r.Context(), auth.TenantOf(r.Context()), id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "task not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "failed to load task: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if t.Status != taskstore.StatusDone {
+			http.Error(w, "task is not finished", http.StatusConflict)
+			return
+		}
+
+		findings, match, err := vulns.TaskFindings(r.Context(), id)
+		if err != nil {
+			http.Error(w, "failed to load vulnerabilities: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if match == nil {
+			http.Error(w, "task was not matched against the vulnerability database", http.StatusConflict)
+			return
+		}
+
+		summary := map[string]int{"total": 0}
+		for _, sev := range []string{vulndb.SeverityCritical, vulndb.SeverityHigh, vulndb.SeverityMedium, vulndb.SeverityLow, vulndb.SeverityUnknown} {
+			summary[strings.ToLower(sev)] = 0
+		}
+		list := make([]vulndb.Finding, 0, len(findings))
+		for _, f := range findings {
+			summary[strings.ToLower(f.Severity)]++
+			summary["total"]++
+			if vulndb.SeverityRank(f.Severity) >= minRank {
+				list = append(list, f)
+			}
+		}
+
+		storage.WriteJSON(w, map[string]any{
+			"zip_id":          id,
+			"matched_at":      match.MatchedAt,
+			"snapshot":        match.Snapshot,
+			"summary":         summary,
+			"vulnerabilities": list,
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package httpapi
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"sbom-serv/internal/storage"
+	"sbom-serv/internal/taskstore"
+	"sbom-serv/internal/webhook"
+)
+
+// WebhookDeliveriesHandler — история доставок уведомлений задачи: GET /scan/{id}/webhooks
+func WebhookDeliveriesHandler(store *taskstore.Store, d *webhook.Dispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathTaskID(w, r)
+		if !ok {
+			return
+		}
+		if !taskVisible(w, r, store, id) {
+			return
+		}
+		deliveries, err := d.List(r.Context(), id)
+		if err != nil {
+			http.Error(w, "failed to list deliveries: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		storage.WriteJSON(w, map[string]any{
+			"zip_id":     id,
+			"deliveries": deliveries,
+		})
+	}
+}
+
+// WebhookRedeliverHandler — повторная отправка уведомлений: POST /scan/{id}/webhooks/redeliver
+func WebhookRedeliverHandler(store *taskstore.Store, d *webhook.Dispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathTaskID(w, r)
+		if !ok {
+			return
+		}
+		if !taskVisible(w, r, store, id) {
+			return
+		}
+		err := d.Redeliver(r.Context(), id)
+		switch {
+		case err == nil:
+		case errors.Is(err, sql.ErrNoRows):
+			http.Error(w, "task not found", http.StatusNotFound)
+			return
+		case errors.Is(err, webhook.ErrNoCallback), errors.Is(err, webhook.ErrNotFinished):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		default:
+			http.Error(w, "failed to redeliver: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		storage.WriteJSON(w, map[string]any{
+			"zip_id": id,
+			"status": "pending",
+		})
+	}
+}
@@ -2,6 +2,8 @@ package httpapi
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"io"
@@ -11,12 +13,30 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
+	"sbom-serv/internal/auth"
 	"sbom-serv/internal/config"
+	"sbom-serv/internal/logging"
+	"sbom-serv/internal/metrics"
 	"sbom-serv/internal/taskstore"
+	"sbom-serv/internal/tracing"
+	"sbom-serv/internal/webhook"
 )
 
-func UploadZipHandler(paths config.UploadPaths, store *taskstore.Store) http.HandlerFunc {
+const (
+	maxProjectLen = 200
+	maxVersionLen = 100
+
+	// Секрет для подписи webhook передаётся заголовком, чтобы не попадать в логи URL
+	callbackSecretHeader = "X-Callback-Secret"
+)
+
+// UploadZipHandler принимает архив. maxBytes <= 0 — размер не ограничен.
+// callback_url проверяется hooks: на внутренние адреса уведомления не шлются.
+func UploadZipHandler(paths config.UploadPaths, store *taskstore.Store, hooks *webhook.Dispatcher, maxBytes int64) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -32,69 +52,185 @@ func UploadZipHandler(paths config.UploadPaths, store *taskstore.Store) http.Han
 			http.Error(w, "empty body", http.StatusBadRequest)
 			return
 		}
+		if maxBytes > 0 {
+			if r.ContentLength > maxBytes {
+				http.Error(w, "archive is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		}
 
-		body, ok := validateZipType(w, r)
+		id := uuid.NewString()
+		tenant := auth.TenantOf(r.Context())
+		tp := paths.Tenant(tenant)
+		zipPath := filepath.Join(tp.Zips, "zip-"+id+".zip")
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("task_id", id))
+
+		ctx, span := tracing.Start(r.Context(), "upload.validate")
+		nt, body, ok := parseUpload(ctx, w, r, store, hooks)
+		if !ok {
+			span.SetStatus(codes.Error, "upload rejected")
+		}
+		span.End()
 		if !ok {
 			return
 		}
+		nt.ID = id
+		nt.Tenant = tenant
+		// сохраняем серверный спан запроса: на него сошлются спаны воркера
+		nt.TraceParent = tracing.TraceParent(r.Context())
 
-		id := uuid.NewString()
-		zipPath := filepath.Join(paths.Zips, "zip-"+id+".zip")
-
-		if err := saveBodyAtomic(zipPath, body); err != nil { // <-- body, не r.Body
+		ctx, span = tracing.Start(r.Context(), "upload.save")
+		if err := tp.Ensure(); err != nil {
+			tracing.End(span, err)
+			http.Error(w, "failed to prepare storage: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := store.Create(ctx, nt); err != nil {
+			tracing.End(span, err)
+			if errors.Is(err, taskstore.ErrQuotaExceeded) {
+				http.Error(w, err.Error(), http.StatusTooManyRequests)
+				return
+			}
+			http.Error(w, "failed to create task: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		size, err := saveBodyAtomic(zipPath, body) // <-- body, не r.Body
+		span.SetAttributes(attribute.Int64("upload.bytes", size))
+		tracing.End(span, err)
+		if err != nil {
+			_ = store.Delete(context.WithoutCancel(r.Context()), id)
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "archive is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
 			http.Error(w, "failed to save zip: "+err.Error(), http.StatusBadRequest)
 			return
 		}
-		if err := store.Enqueue(r.Context(), id); err != nil {
+
+		ctx, span = tracing.Start(r.Context(), "upload.enqueue")
+		err = store.Enqueue(ctx, id)
+		tracing.End(span, err)
+		if errors.Is(err, sql.ErrNoRows) {
+			// задачу отменили, пока архив загружался
+			_ = os.Remove(zipPath)
+			http.Error(w, "task was cancelled during upload", http.StatusConflict)
+			return
+		}
+		if err != nil {
 			_ = os.Remove(zipPath)
+			_ = store.Delete(context.WithoutCancel(r.Context()), id)
 			http.Error(w, "failed to enqueue: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		logging.FromContext(r.Context()).Info("task enqueued",
+			"task_id", id, "tenant", tenant, "project", nt.Project, "version", nt.ProjectVersion, "bytes", size)
+		metrics.TasksEnqueued.Inc()
+		metrics.UploadBytes.Add(float64(size))
+		metrics.UploadSize.Observe(float64(size))
 
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(map[string]any{
+		resp := map[string]any{
 			"zip_id": id,
 			"status": "queued",
-		})
+		}
+		if nt.Project != "" {
+			resp["project"] = nt.Project
+		}
+		if nt.ProjectVersion != "" {
+			resp["version"] = nt.ProjectVersion
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// parseUpload проверяет тип архива, параметры запроса и квоту арендатора.
+// При ошибке ответ уже записан.
+func parseUpload(ctx context.Context, w http.ResponseWriter, r *http.Request, store *taskstore.Store, hooks *webhook.Dispatcher) (taskstore.NewTask, io.Reader, bool) {
+	body, ok := validateZipType(w, r)
+	if !ok {
+		return taskstore.NewTask{}, nil, false
+	}
+
+	nt := taskstore.NewTask{
+		Project:        strings.TrimSpace(r.URL.Query().Get("project")),
+		ProjectVersion: strings.TrimSpace(r.URL.Query().Get("version")),
+		RequestID:      logging.RequestID(ctx),
+	}
+	if ident, ok := auth.FromContext(ctx); ok {
+		nt.ClientID = ident.ClientID
 	}
+	if len(nt.Project) > maxProjectLen {
+		http.Error(w, "project name too long", http.StatusBadRequest)
+		return nt, nil, false
+	}
+	if nt.ProjectVersion != "" && nt.Project == "" {
+		http.Error(w, "version requires project", http.StatusBadRequest)
+		return nt, nil, false
+	}
+	if len(nt.ProjectVersion) > maxVersionLen {
+		http.Error(w, "version too long", http.StatusBadRequest)
+		return nt, nil, false
+	}
+	if cb := strings.TrimSpace(r.URL.Query().Get("callback_url")); cb != "" {
+		if err := hooks.CheckURL(ctx, cb); err != nil {
+			http.Error(w, "invalid callback_url: "+err.Error(), http.StatusBadRequest)
+			return nt, nil, false
+		}
+		nt.CallbackURL = cb
+		nt.CallbackSecret = r.Header.Get(callbackSecretHeader)
+	}
+
+	if err := store.CheckQuota(ctx, auth.TenantOf(ctx)); err != nil {
+		if errors.Is(err, taskstore.ErrQuotaExceeded) {
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return nt, nil, false
+		}
+		http.Error(w, "failed to check quota: "+err.Error(), http.StatusInternalServerError)
+		return nt, nil, false
+	}
+	return nt, body, true
 }
 
-func saveBodyAtomic(finalPath string, body io.Reader) error {
+// saveBodyAtomic сохраняет тело запроса и возвращает его размер.
+func saveBodyAtomic(finalPath string, body io.Reader) (int64, error) {
 	if err := os.MkdirAll(filepath.Dir(finalPath), 0o755); err != nil {
-		return err
+		return 0, err
 	}
 
 	tmpDir := filepath.Dir(finalPath)
 	f, err := os.CreateTemp(tmpDir, ".upload-*.tmp")
 	if err != nil {
-		return err
+		return 0, err
 	}
 	tmpName := f.Name()
 	defer func() { _ = os.Remove(tmpName) }()
 
-	if _, err := io.Copy(f, body); err != nil {
+	n, err := io.Copy(f, body)
+	if err != nil {
 		_ = f.Close()
-		return err
+		return n, err
 	}
 	if err := f.Sync(); err != nil {
 		_ = f.Close()
-		return err
+		return n, err
 	}
 	if err := f.Close(); err != nil {
-		return err
+		return n, err
 	}
 
 	if err := os.Rename(tmpName, finalPath); err != nil {
-		return err
+		return n, err
 	}
 
 	if _, err := os.Stat(finalPath); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return errors.New("zip not created")
+			return n, errors.New("zip not created")
 		}
-		return err
+		return n, err
 	}
-	return nil
+	return n, nil
 }
 
 func validateZipType(w http.ResponseWriter, r *http.Request) (io.Reader, bool) {
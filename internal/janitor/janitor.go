@@ -0,0 +1,424 @@
+package janitor
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sbom-serv/internal/config"
+	"sbom-serv/internal/logging"
+	"sbom-serv/internal/metrics"
+	"sbom-serv/internal/tracing"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type RunningAction string
+
+const (
+	RunningFail    RunningAction = "fail"
+	RunningRequeue RunningAction = "requeue"
+)
+
+type Config struct {
+	// Как часто запускать чистку
+	Every time.Duration
+
+	// Через сколько времени удалять done/failed задачи + файлы
+	Retention time.Duration
+
+	// Чистка зависших running (0 = выключено)
+	RunningTimeout time.Duration
+
+	// Что делать с зависшими running: "fail" или "requeue"
+	RunningTimeoutAction RunningAction
+
+	// Через сколько удалять *.tmp и задачи, архив которых так и не загрузился
+	// (status queued, stage uploading: процесс упал во время загрузки)
+	TmpMaxAge time.Duration
+
+	// Ограничение количества задач на один прогон
+	BatchSize int
+
+	// Advisory lock key (одно число на весь кластер)
+	AdvisoryLockKey int64
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Every:                1 * time.Minute,
+		Retention:            24 * time.Hour,
+		RunningTimeout:       30 * time.Minute,
+		RunningTimeoutAction: RunningFail,
+		TmpMaxAge:            10 * time.Minute,
+		BatchSize:            500,
+		// любое постоянное число, главное одинаковое на всех инстансах:
+		AdvisoryLockKey: 9876543,
+	}
+}
+
+type Janitor struct {
+	db       *sql.DB
+	paths    config.UploadPaths
+	cfg      Config
+	log      *slog.Logger
+	onFailed []func(ctx context.Context, id string)
+}
+
+func New(db *sql.DB, paths config.UploadPaths, cfg Config) *Janitor {
+	j := &Janitor{
+		db:    db,
+		paths: paths,
+		cfg:   cfg,
+		log:   logging.Component("janitor"),
+	}
+	if j.cfg.BatchSize <= 0 {
+		j.cfg.BatchSize = 500
+	}
+	if j.cfg.Every <= 0 {
+		j.cfg.Every = 1 * time.Minute
+	}
+	if j.cfg.TmpMaxAge <= 0 {
+		j.cfg.TmpMaxAge = 10 * time.Minute
+	}
+	return j
+}
+
+// OnTaskFailed регистрирует обработчик для задач, которые janitor перевёл в failed.
+func (j *Janitor) OnTaskFailed(fn func(ctx context.Context, id string)) {
+	j.onFailed = append(j.onFailed, fn)
+}
+
+func (j *Janitor) Start(ctx context.Context) {
+	ticker := time.NewTicker(j.cfg.Every)
+	defer ticker.Stop()
+
+	// Можно сразу прогнать один раз на старте
+	j.RunOnce(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			j.RunOnce(ctx)
+		}
+	}
+}
+
+// RunOnce выполняет один прогон чистки. Возвращает false, если прогон
+// не состоялся: ошибка БД или чистку сейчас выполняет другой инстанс.
+func (j *Janitor) RunOnce(ctx context.Context) bool {
+	ctx, span := tracing.Start(ctx, "janitor.run")
+	defer span.End()
+
+	conn, err := j.db.Conn(ctx)
+	if err != nil {
+		j.log.Error("db conn", "err", err)
+		span.SetStatus(codes.Error, err.Error())
+		return false
+	}
+	defer conn.Close()
+
+	ok, err := tryAdvisoryLock(ctx, conn, j.cfg.AdvisoryLockKey)
+	if err != nil {
+		metrics.JanitorLock.WithLabelValues("error").Inc()
+		j.log.Error("try advisory lock", "err", err)
+		span.SetStatus(codes.Error, err.Error())
+		return false
+	}
+	span.SetAttributes(attribute.Bool("janitor.lock_acquired", ok))
+	if !ok {
+		metrics.JanitorLock.WithLabelValues("busy").Inc()
+		return false
+	}
+	metrics.JanitorLock.WithLabelValues("acquired").Inc()
+	defer func() {
+		_ = advisoryUnlock(context.Background(), conn, j.cfg.AdvisoryLockKey)
+	}()
+
+	//обработка зависших running
+	if j.cfg.RunningTimeout > 0 {
+		if err := j.step(ctx, "janitor.stuck_running", func(ctx context.Context) error {
+			return j.handleStuckRunning(ctx, conn)
+		}); err != nil {
+			j.log.Error("handle stuck running tasks", "err", err)
+		}
+	}
+
+	//старые done/failed и их файлы (срок хранения может быть задан арендатору)
+	if err := j.step(ctx, "janitor.retention", func(ctx context.Context) error {
+		return j.cleanupOldDoneFailed(ctx, conn)
+	}); err != nil {
+		j.log.Error("cleanup expired tasks", "err", err)
+	}
+
+	//недогруженные архивы: такие задачи занимают лимит одновременных задач
+	if j.cfg.TmpMaxAge > 0 {
+		if err := j.step(ctx, "janitor.stale_uploads", func(ctx context.Context) error {
+			return j.cleanupStaleUploads(ctx, conn)
+		}); err != nil {
+			j.log.Error("cleanup stale uploads", "err", err)
+		}
+	}
+
+	//лимит хранимых задач арендатора
+	if err := j.step(ctx, "janitor.quotas", func(ctx context.Context) error {
+		return j.enforceTaskQuotas(ctx, conn)
+	}); err != nil {
+		j.log.Error("enforce task quotas", "err", err)
+	}
+
+	//*.tmp в папках всех арендаторов
+	tenants, err := j.paths.AllTenants()
+	if err != nil {
+		j.log.Error("list tenant dirs", "err", err)
+	}
+	for _, tp := range tenants {
+		if err := cleanupTmpFiles(tp.Results, j.cfg.TmpMaxAge); err != nil {
+			j.log.Error("cleanup tmp files", "dir", tp.Results, "err", err)
+		}
+		if err := cleanupTmpFiles(tp.Zips, j.cfg.TmpMaxAge); err != nil {
+			j.log.Error("cleanup tmp files", "dir", tp.Zips, "err", err)
+		}
+	}
+	return true
+}
+
+// step выполняет шаг прогона в отдельном спане.
+func (j *Janitor) step(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	ctx, span := tracing.Start(ctx, name)
+	err := fn(ctx)
+	tracing.End(span, err)
+	return err
+}
+
+func tryAdvisoryLock(ctx context.Context, conn *sql.Conn, key int64) (bool, error) {
+	var ok bool
+	err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&ok)
+	return ok, err
+}
+
+func advisoryUnlock(ctx context.Context, conn *sql.Conn, key int64) error {
+	_, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, key)
+	return err
+}
+
+func (j *Janitor) handleStuckRunning(ctx context.Context, conn *sql.Conn) error {
+	seconds := int64(j.cfg.RunningTimeout.Seconds())
+	if seconds <= 0 {
+		return nil
+	}
+
+	var status string
+	var errText string
+	action := RunningFail
+
+	switch j.cfg.RunningTimeoutAction {
+	case RunningRequeue:
+		status = "queued"
+		errText = "requeued by janitor: running too long"
+		action = RunningRequeue
+	default:
+		status = "failed"
+		errText = "failed by janitor: running too long"
+	}
+
+	rows, err := conn.QueryContext(ctx, `
+		UPDATE sbom_tasks
+		SET status = $1::sbom_task_status,
+		    ts = now(),
+		    error = $2,
+		    stage = CASE WHEN $1::sbom_task_status = 'queued' THEN NULL ELSE stage END,
+		    progress = NULL
+		WHERE status = 'running'
+		  AND ts < now() - ($3 * interval '1 second')
+		RETURNING id::text, tenant_id
+	`, status, errText, seconds)
+	if err != nil {
+		return err
+	}
+	items, err := scanTaskRefs(rows)
+	if err != nil {
+		return err
+	}
+	metrics.JanitorStuck.WithLabelValues(string(action)).Add(float64(len(items)))
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("janitor.tasks", len(items)))
+	for _, it := range items {
+		j.log.Warn("stuck running task", "task_id", it.id, "tenant", it.tenant, "action", action)
+	}
+
+	if status == "failed" {
+		metrics.TasksFailed.WithLabelValues("janitor_timeout").Add(float64(len(items)))
+		for _, it := range items {
+			for _, fn := range j.onFailed {
+				fn(ctx, it.id)
+			}
+		}
+	}
+	return nil
+}
+
+type taskRef struct {
+	id     string
+	tenant string
+}
+
+func (j *Janitor) cleanupOldDoneFailed(ctx context.Context, conn *sql.Conn) error {
+	// Retention <= 0 выключает общую чистку, но не сроки, заданные арендаторам
+	seconds := int64(j.cfg.Retention.Seconds())
+	if seconds < 0 {
+		seconds = 0
+	}
+
+	rows, err := conn.QueryContext(ctx, `
+		SELECT t.id::text, t.tenant_id
+		FROM sbom_tasks t
+		LEFT JOIN sbom_tenants q ON q.id = t.tenant_id
+		WHERE t.status IN ('done','failed')
+		  AND t.pinned_at IS NULL
+		  AND NOT EXISTS (SELECT 1 FROM sbom_protected_tasks p WHERE p.task_id = t.id)
+		  AND t.ts < now() - (COALESCE(q.retention_seconds, NULLIF($1, 0)) * interval '1 second')
+		ORDER BY t.ts ASC
+		LIMIT $2
+	`, seconds, j.cfg.BatchSize)
+	if err != nil {
+		return err
+	}
+	items, err := scanTaskRefs(rows)
+	if err != nil {
+		return err
+	}
+
+	j.deleteTasks(ctx, conn, items, "retention")
+	return nil
+}
+
+// cleanupStaleUploads удаляет задачи, архив которых загружается дольше
+// TmpMaxAge: после падения процесса во время загрузки они остаются
+// в queued/uploading и занимают max_active_tasks арендатора.
+func (j *Janitor) cleanupStaleUploads(ctx context.Context, conn *sql.Conn) error {
+	rows, err := conn.QueryContext(ctx, `
+		SELECT id::text, tenant_id
+		FROM sbom_tasks
+		WHERE status = 'queued' AND stage = 'uploading'
+		  AND ts < now() - ($1 * interval '1 millisecond')
+		ORDER BY ts ASC
+		LIMIT $2
+	`, j.cfg.TmpMaxAge.Milliseconds(), j.cfg.BatchSize)
+	if err != nil {
+		return err
+	}
+	items, err := scanTaskRefs(rows)
+	if err != nil {
+		return err
+	}
+
+	j.deleteTasks(ctx, conn, items, "stale_upload")
+	return nil
+}
+
+// enforceTaskQuotas удаляет самые старые done/failed задачи арендаторов,
+// у которых их больше, чем sbom_tenants.max_tasks. Закреплённые задачи
+// в лимите не учитываются, текущие SBOM версий проектов (sbom_protected_tasks)
+// учитываются первыми и не удаляются.
+func (j *Janitor) enforceTaskQuotas(ctx context.Context, conn *sql.Conn) error {
+	rows, err := conn.QueryContext(ctx, `
+		SELECT id::text, tenant_id
+		FROM (
+			SELECT t.id, t.tenant_id, q.max_tasks, p.task_id IS NOT NULL AS protected,
+			       row_number() OVER (PARTITION BY t.tenant_id
+			                          ORDER BY p.task_id IS NOT NULL DESC, t.ts DESC) AS rn
+			FROM sbom_tasks t
+			JOIN sbom_tenants q ON q.id = t.tenant_id
+			LEFT JOIN sbom_protected_tasks p ON p.task_id = t.id
+			WHERE q.max_tasks IS NOT NULL
+			  AND t.status IN ('done','failed')
+			  AND t.pinned_at IS NULL
+		) x
+		WHERE rn > max_tasks AND NOT protected
+		LIMIT $1
+	`, j.cfg.BatchSize)
+	if err != nil {
+		return err
+	}
+	items, err := scanTaskRefs(rows)
+	if err != nil {
+		return err
+	}
+
+	j.deleteTasks(ctx, conn, items, "quota")
+	return nil
+}
+
+func scanTaskRefs(rows *sql.Rows) ([]taskRef, error) {
+	defer rows.Close()
+
+	var items []taskRef
+	for rows.Next() {
+		var it taskRef
+		if err := rows.Scan(&it.id, &it.tenant); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// deleteTasks удаляет файлы и строки задач; reason — метка для метрики.
+func (j *Janitor) deleteTasks(ctx context.Context, conn *sql.Conn, items []taskRef, reason string) {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("janitor.tasks", len(items)))
+	for _, it := range items {
+		id := it.id
+		j.paths.Tenant(it.tenant).RemoveTask(id)
+
+		_, err := conn.ExecContext(ctx, `DELETE FROM sbom_tasks WHERE id = $1`, id)
+		if err != nil {
+			j.log.Error("delete task", "task_id", id, "tenant", it.tenant, "err", err)
+			continue
+		}
+		j.log.Info("task deleted", "task_id", id, "tenant", it.tenant, "reason", reason)
+		metrics.JanitorDeleted.WithLabelValues(reason).Inc()
+	}
+}
+
+func cleanupTmpFiles(dir string, maxAge time.Duration) error {
+	if dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		if !strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+		full := filepath.Join(dir, name)
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if info.ModTime().Before(cutoff) {
+			_ = os.Remove(full)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"sbom-serv/internal/taskstore"
+)
+
+// queueCollector читает число задач из БД при каждом опросе: очередь общая
+// для всех инстансов, поэтому счётчики в памяти одного процесса её не покажут.
+type queueCollector struct {
+	store    *taskstore.Store
+	tasks    *prometheus.Desc
+	oldest   *prometheus.Desc
+	scrapeOK *prometheus.Desc
+}
+
+// RegisterQueue добавляет метрики очереди задач.
+func RegisterQueue(store *taskstore.Store) {
+	Registry.MustRegister(&queueCollector{
+		store: store,
+		tasks: prometheus.NewDesc(namespace+"_tasks",
+			"Tasks in the database by status; uploading — archive upload still in progress.",
+			[]string{"status"}, nil),
+		oldest: prometheus.NewDesc(namespace+"_queue_oldest_task_age_seconds",
+			"Age of the oldest task waiting in the queue (0 when the queue is empty).", nil, nil),
+		scrapeOK: prometheus.NewDesc(namespace+"_queue_scrape_success",
+			"Whether the queue statistics query succeeded.", nil, nil),
+	})
+}
+
+func (c *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.tasks
+	ch <- c.oldest
+	ch <- c.scrapeOK
+}
+
+func (c *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	st, err := c.store.QueueStats(ctx, taskstore.AnyTenant)
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(c.scrapeOK, prometheus.GaugeValue, 0)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.scrapeOK, prometheus.GaugeValue, 1)
+
+	for status, n := range st.Counts {
+		ch <- prometheus.MustNewConstMetric(c.tasks, prometheus.GaugeValue, float64(n), string(status))
+	}
+	ch <- prometheus.MustNewConstMetric(c.tasks, prometheus.GaugeValue, float64(st.Uploading), "uploading")
+
+	var age float64
+	if st.OldestQueued != nil {
+		age = time.Since(*st.OldestQueued).Seconds()
+	}
+	ch <- prometheus.MustNewConstMetric(c.oldest, prometheus.GaugeValue, age)
+}
+
+// RegisterWorkerSlots добавляет занятость слотов воркера этого инстанса.
+func RegisterWorkerSlots(busy, capacity func() int) {
+	Registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "worker_slots_busy",
+			Help:      "Worker slots currently processing a task.",
+		}, func() float64 { return float64(busy()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "worker_slots",
+			Help:      "Configured number of worker slots.",
+		}, func() float64 { return float64(capacity()) }),
+	)
+}
@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"sbom-serv/internal/logging"
+)
+
+// Middleware измеряет длительность запросов. route возвращает шаблон маршрута
+// (например, "GET /scan/{id}/logs"): подставлять сам путь нельзя — id задач
+// превратят метрику в миллионы рядов.
+func Middleware(next http.Handler, route func(r *http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		rec := logging.NewStatusRecorder(w)
+
+		next.ServeHTTP(rec, r)
+
+		pattern := route(r)
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+		HTTPDuration.WithLabelValues(pattern, strconv.Itoa(rec.Status())).Observe(time.Since(started).Seconds())
+	})
+}
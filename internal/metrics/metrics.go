@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "sbom"
+
+// Registry — отдельный реестр, чтобы /metrics не зависел от глобального
+// состояния библиотек.
+var Registry = prometheus.NewRegistry()
+
+var (
+	TasksEnqueued = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tasks_enqueued_total",
+		Help:      "Tasks accepted and put into the queue.",
+	})
+
+	TasksCompleted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tasks_completed_total",
+		Help:      "Tasks finished with status done.",
+	})
+
+	// reason — этап, на котором упала обработка (validating, extracting, cataloging,
+	// converting, storing), timeout, canceled или janitor_timeout.
+	TasksFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tasks_failed_total",
+		Help:      "Tasks finished with status failed, by reason.",
+	}, []string{"reason"})
+
+	QueueWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "task_queue_wait_seconds",
+		Help:      "Time a task spent queued before a worker claimed it.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600, 1800, 3600},
+	})
+
+	ScanDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "task_processing_seconds",
+		Help:      "Time from claim to finish of a task (extraction and scan), by final status.",
+		Buckets:   []float64{1, 5, 10, 30, 60, 120, 300, 600, 1200, 1800, 3600, 7200},
+	}, []string{"status"})
+
+	UploadBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upload_bytes_total",
+		Help:      "Bytes of accepted archives.",
+	})
+
+	UploadSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upload_size_bytes",
+		Help:      "Size of accepted archives.",
+		Buckets:   prometheus.ExponentialBuckets(64<<10, 4, 10), // 64KiB .. 16GiB
+	})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route pattern and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "code"})
+
+	// reason — retention (истёк срок хранения), quota (лимит арендатора)
+	// или stale_upload (архив не загрузился за janitor.tmp_max_age)
+	JanitorDeleted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "janitor_deleted_tasks_total",
+		Help:      "Tasks deleted by the janitor, by reason.",
+	}, []string{"reason"})
+
+	JanitorStuck = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "janitor_stuck_tasks_total",
+		Help:      "Running tasks the janitor failed or requeued after the timeout, by action.",
+	}, []string{"action"})
+
+	// result — acquired, busy (лок у другого инстанса) или error
+	JanitorLock = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "janitor_lock_attempts_total",
+		Help:      "Janitor advisory lock acquisition attempts, by result.",
+	}, []string{"result"})
+
+	// Уязвимости, появившиеся в закреплённых задачах после обновления базы
+	VulnAlerts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "vuln_alerts_total",
+		Help:      "New vulnerabilities found in pinned tasks after a vulnerability DB refresh, by severity.",
+	}, []string{"severity"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		TasksEnqueued, TasksCompleted, TasksFailed,
+		QueueWait, ScanDuration,
+		UploadBytes, UploadSize,
+		HTTPDuration,
+		JanitorDeleted, JanitorStuck, JanitorLock,
+		VulnAlerts,
+	)
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
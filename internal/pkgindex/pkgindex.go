@@ -0,0 +1,345 @@
+package pkgindex
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"sbom-serv/internal/config"
+	"sbom-serv/internal/sbom"
+	"sbom-serv/internal/vulndb"
+)
+
+// Store — индекс пакетов из результатов задач (sbom_task_packages): по нему
+// ищут, в каких артефактах есть пакет.
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Index заменяет индекс пакетов задачи. Копии одного пакета (один и тот же
+// jar в нескольких местах архива) индексируются один раз.
+func (s *Store) Index(ctx context.Context, taskID string, doc *sbom.Document) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// FOR UPDATE: индексация воркером и командой packages reindex идут по очереди
+	var id string
+	if err := tx.QueryRowContext(ctx, `SELECT id::text FROM sbom_tasks WHERE id = $1 FOR UPDATE`, taskID).Scan(&id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sbom_task_packages WHERE task_id = $1`, taskID); err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO sbom_task_packages(task_id, name, version, type, purl, purl_base, cpes, license)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	seen := make(map[[4]string]bool, len(doc.Artifacts))
+	for _, p := range doc.Artifacts {
+		key := [4]string{p.Type, p.Name, p.Version, p.PURL}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		cpes := p.CPEs
+		if cpes == nil {
+			cpes = sbom.CPEs{}
+		}
+		cpesJSON, _ := json.Marshal(cpes)
+		_, err := stmt.ExecContext(ctx, taskID, p.Name, p.Version, p.Type, p.PURL, sbom.BasePURL(p.PURL),
+			string(cpesJSON), p.Licenses.Expression())
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE sbom_tasks SET packages_indexed_at = now() WHERE id = $1`, taskID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// TaskRef — завершённая задача, которую можно проиндексировать заново.
+type TaskRef struct {
+	ID     string
+	Tenant string
+}
+
+// Reindex заново индексирует сохранённый SBOM задачи.
+func (s *Store) Reindex(ctx context.Context, paths config.UploadPaths, t TaskRef) (int, error) {
+	doc, err := sbom.ReadFile(paths.Tenant(t.Tenant).ResultPath(t.ID))
+	if err != nil {
+		return 0, err
+	}
+	return len(doc.Artifacts), s.Index(ctx, t.ID, doc)
+}
+
+// DoneTask находит завершённую задачу по id; sql.ErrNoRows — задачи нет
+// или она ещё не завершена.
+func (s *Store) DoneTask(ctx context.Context, id string) (TaskRef, error) {
+	t := TaskRef{ID: id}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT tenant_id FROM sbom_tasks WHERE id = $1 AND status = 'done'
+	`, id).Scan(&t.Tenant)
+	return t, err
+}
+
+// Unindexed — завершённые задачи без индекса пакетов (all — все завершённые),
+// страницами по id: after — последний id предыдущей страницы.
+func (s *Store) Unindexed(ctx context.Context, tenant string, all bool, after string, limit int) ([]TaskRef, error) {
+	if after == "" {
+		after = "00000000-0000-0000-0000-000000000000"
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id::text, tenant_id
+		FROM sbom_tasks
+		WHERE status = 'done'
+		  AND ($1 = '' OR tenant_id = $1)
+		  AND id > $2::uuid
+		  AND ($3 OR packages_indexed_at IS NULL)
+		ORDER BY id
+		LIMIT $4
+	`, tenant, after, all, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []TaskRef
+	for rows.Next() {
+		var t TaskRef
+		if err := rows.Scan(&t.ID, &t.Tenant); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// Query — что искать. Нужно хотя бы одно из Name, PURL, CPE.
+type Query struct {
+	Tenant string
+	// Имя пакета без учёта регистра
+	Name string
+	// purl; с версией ("pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1") —
+	// только эта версия
+	PURL string
+	CPE  string
+	// Тип syft: npm, java-archive, python, ...
+	Type string
+	// Диапазон версий: "< 2.17.1", ">= 2.0, < 2.15.0"
+	Versions string
+	Project  string
+	// Сколько задач вернуть
+	Limit int
+}
+
+var ErrEmptyQuery = errors.New("name, purl or cpe is required")
+
+type Package struct {
+	Name    string   `json:"name"`
+	Version string   `json:"version"`
+	Type    string   `json:"type"`
+	PURL    string   `json:"purl,omitempty"`
+	CPEs    []string `json:"cpes"`
+	License string   `json:"license,omitempty"`
+}
+
+// Match — задача, в результате которой нашлись пакеты.
+type Match struct {
+	ZipID    string     `json:"zip_id"`
+	Project  *string    `json:"project,omitempty"`
+	Version  *string    `json:"project_version,omitempty"`
+	TS       time.Time  `json:"ts"`
+	PinnedAt *time.Time `json:"pinned_at,omitempty"`
+	Packages []Package  `json:"packages"`
+}
+
+// where собирает условия запроса из заданных фильтров: каждый "?" в условии
+// заменяется на очередной параметр $N.
+type where struct {
+	conds []string
+	args  []any
+}
+
+func (w *where) add(cond string, args ...any) {
+	for _, a := range args {
+		w.args = append(w.args, a)
+		cond = strings.Replace(cond, "?", "$"+strconv.Itoa(len(w.args)), 1)
+	}
+	w.conds = append(w.conds, cond)
+}
+
+// param добавляет параметр без условия и возвращает его $N.
+func (w *where) param(arg any) string {
+	w.args = append(w.args, arg)
+	return "$" + strconv.Itoa(len(w.args))
+}
+
+// and — накопленные условия через AND; список условий начинается заново.
+func (w *where) and() string {
+	s := strings.Join(w.conds, " AND ")
+	w.conds = nil
+	return s
+}
+
+// Search ищет пакеты в завершённых задачах арендатора, новые задачи первыми.
+// truncated — задач больше, чем q.Limit.
+func (s *Store) Search(ctx context.Context, q Query) (matches []Match, truncated bool, err error) {
+	if q.Name == "" && q.PURL == "" && q.CPE == "" {
+		return nil, false, ErrEmptyQuery
+	}
+	if q.Limit <= 0 {
+		q.Limit = 100
+	}
+	var versions vulndb.VersionRange
+	if q.Versions != "" {
+		if versions, err = vulndb.ParseVersionRange(q.Versions); err != nil {
+			return nil, false, err
+		}
+	}
+
+	// задачи выбираются страницами: диапазон версий проверяется в Go, и
+	// часть задач страницы может в него не попасть
+	matches = []Match{}
+	var last *Match
+	for {
+		page, more, err := s.searchPage(ctx, q, last, q.Limit+1)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, m := range page {
+			if versions != nil {
+				m.Packages = slices.DeleteFunc(m.Packages, func(p Package) bool { return !versions.Contains(p.Version) })
+				if len(m.Packages) == 0 {
+					continue
+				}
+			}
+			if len(matches) == q.Limit {
+				return matches, true, nil
+			}
+			matches = append(matches, m)
+		}
+		if !more {
+			return matches, false, nil
+		}
+		last = &page[len(page)-1]
+	}
+}
+
+// searchPage — до limit задач старше after с пакетами, подходящими под
+// запрос (без диапазона версий). more — за страницей могут быть ещё задачи.
+func (s *Store) searchPage(ctx context.Context, q Query, after *Match, limit int) (page []Match, more bool, err error) {
+	var w where
+	if q.Name != "" {
+		w.add("lower(p.name) = lower(?)", q.Name)
+	}
+	if q.PURL != "" {
+		base, version := splitPURL(q.PURL)
+		w.add("lower(p.purl_base) = lower(?)", base)
+		if version != "" {
+			w.add("p.version = ?", version)
+		}
+	}
+	if q.CPE != "" {
+		w.add("p.cpes @> jsonb_build_array(?::text)", q.CPE)
+	}
+	if q.Type != "" {
+		w.add("p.type = ?", q.Type)
+	}
+	pkgCond := w.and()
+
+	w.add("t.tenant_id = ?", q.Tenant)
+	w.add("t.status = 'done'")
+	if q.Project != "" {
+		w.add("t.project = ?", q.Project)
+	}
+	if after != nil {
+		w.add("(t.ts, t.id) < (?::timestamptz, ?::uuid)", after.TS, after.ZipID)
+	}
+	taskCond := w.and()
+	limitParam := w.param(limit)
+
+	rows, err := s.db.QueryContext(ctx, `
+		WITH tasks AS (
+			SELECT t.id, t.project, t.project_version, t.ts, t.pinned_at
+			FROM sbom_tasks t
+			WHERE `+taskCond+`
+			  AND t.id IN (SELECT p.task_id FROM sbom_task_packages p WHERE `+pkgCond+`)
+			ORDER BY t.ts DESC, t.id DESC
+			LIMIT `+limitParam+`
+		)
+		SELECT t.id::text, t.project, t.project_version, t.ts, t.pinned_at,
+		       p.name, p.version, p.type, p.purl, p.cpes, p.license
+		FROM tasks t
+		JOIN sbom_task_packages p ON p.task_id = t.id
+		WHERE `+pkgCond+`
+		ORDER BY t.ts DESC, t.id DESC, p.name, p.version
+	`, w.args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m Match
+		var project, version sql.NullString
+		var pinned sql.NullTime
+		var p Package
+		var cpes []byte
+		err := rows.Scan(&m.ZipID, &project, &version, &m.TS, &pinned,
+			&p.Name, &p.Version, &p.Type, &p.PURL, &cpes, &p.License)
+		if err != nil {
+			return nil, false, err
+		}
+		if err := json.Unmarshal(cpes, &p.CPEs); err != nil {
+			return nil, false, err
+		}
+
+		if n := len(page); n > 0 && page[n-1].ZipID == m.ZipID {
+			page[n-1].Packages = append(page[n-1].Packages, p)
+			continue
+		}
+		if project.Valid {
+			m.Project = &project.String
+		}
+		if version.Valid {
+			m.Version = &version.String
+		}
+		if pinned.Valid {
+			m.PinnedAt = &pinned.Time
+		}
+		m.Packages = []Package{p}
+		page = append(page, m)
+	}
+	return page, len(page) == limit, rows.Err()
+}
+
+// splitPURL отделяет версию от purl; квалификаторы и подпуть отбрасываются.
+func splitPURL(purl string) (base, version string) {
+	base = sbom.BasePURL(purl)
+	if rest, ok := strings.CutPrefix(purl, base+"@"); ok {
+		version, _, _ = strings.Cut(rest, "?")
+		version, _, _ = strings.Cut(version, "#")
+	}
+	return base, version
+}
@@ -0,0 +1,243 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"sbom-serv/internal/sbom"
+	"sbom-serv/internal/vulndb"
+)
+
+// Имена проверок в отчёте
+const (
+	CheckLicenses        = "licenses"
+	CheckBanned          = "banned"
+	CheckVulnerabilities = "vulnerabilities"
+	CheckKnownLicenses   = "known_licenses"
+	CheckFields          = "fields"
+)
+
+// BannedPackage — запрещённый пакет. Пакет задаётся именем (и, если нужно,
+// типом syft: npm, java-archive, python, ...) или purl без версии.
+type BannedPackage struct {
+	Name string `json:"name,omitempty" yaml:"name"`
+	Type string `json:"type,omitempty" yaml:"type"`
+	PURL string `json:"purl,omitempty" yaml:"purl"`
+	// Запрещённые версии: "2.14.1", "< 2.17.1", ">= 1.0, < 1.4". Подходит
+	// любой элемент списка; условия через запятую должны выполняться все.
+	// Пустой список — запрещены все версии.
+	Versions []string `json:"versions,omitempty" yaml:"versions"`
+	Reason   string   `json:"reason,omitempty" yaml:"reason"`
+}
+
+// VulnPolicy — допустимые уязвимости.
+type VulnPolicy struct {
+	// Наибольший допустимый уровень: low, medium, high, critical;
+	// none — недопустима любая находка
+	MaxSeverity string `json:"max_severity" yaml:"max_severity"`
+	// Идентификаторы или алиасы уязвимостей, которые не учитываются
+	Ignore []string `json:"ignore,omitempty" yaml:"ignore"`
+}
+
+// Requirements — обязательные сведения о пакетах в SBOM.
+type Requirements struct {
+	// У каждого пакета должна быть лицензия (не пустая и не NOASSERTION)
+	KnownLicenses bool `json:"known_licenses,omitempty" yaml:"known_licenses"`
+	// Обязательные поля пакета: version, purl, type
+	Fields []string `json:"fields,omitempty" yaml:"fields"`
+}
+
+var packageFields = map[string]func(p sbom.Package) string{
+	"version": func(p sbom.Package) string { return p.Version },
+	"purl":    func(p sbom.Package) string { return p.PURL },
+	"type":    func(p sbom.Package) string { return p.Type },
+}
+
+// CheckResult — итог одной проверки.
+type CheckResult struct {
+	Check      string      `json:"check"`
+	Verdict    string      `json:"verdict"`
+	Violations []Violation `json:"violations"`
+	// Почему проверку не удалось выполнить
+	Error string `json:"error,omitempty"`
+}
+
+// Violation — пакет, нарушивший правило.
+type Violation struct {
+	Package PackageRef `json:"package"`
+	Detail  string     `json:"detail"`
+}
+
+func (b *BannedPackage) validate() error {
+	if b.Name == "" && b.PURL == "" {
+		return errors.New("banned: name or purl is required")
+	}
+	if b.PURL != "" && !strings.HasPrefix(b.PURL, "pkg:") {
+		return fmt.Errorf("banned: invalid purl %q", b.PURL)
+	}
+	for _, v := range b.Versions {
+		if _, err := vulndb.ParseVersionRange(v); err != nil {
+			return fmt.Errorf("banned %s: %w", b.title(), err)
+		}
+	}
+	return nil
+}
+
+func (b *BannedPackage) title() string {
+	if b.PURL != "" {
+		return b.PURL
+	}
+	return b.Name
+}
+
+func (b *BannedPackage) matches(p sbom.Package) bool {
+	if b.PURL != "" && !strings.EqualFold(b.PURL, sbom.BasePURL(p.PURL)) {
+		return false
+	}
+	if b.Name != "" && !strings.EqualFold(b.Name, p.Name) {
+		return false
+	}
+	if b.Type != "" && !strings.EqualFold(b.Type, p.Type) {
+		return false
+	}
+	if len(b.Versions) == 0 {
+		return true
+	}
+	for _, v := range b.Versions {
+		// диапазоны проверены при сохранении политики
+		if r, err := vulndb.ParseVersionRange(v); err == nil && r.Contains(p.Version) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkBanned(banned []BannedPackage, pkgs []sbom.Package) CheckResult {
+	res := CheckResult{Check: CheckBanned}
+	for _, p := range pkgs {
+		for _, b := range banned {
+			if !b.matches(p) {
+				continue
+			}
+			detail := "banned " + b.title()
+			if b.Reason != "" {
+				detail += ": " + b.Reason
+			}
+			res.Violations = append(res.Violations, Violation{Package: refOf(p), Detail: detail})
+			break
+		}
+	}
+	return res.done()
+}
+
+// Уровень none: допустимых уязвимостей нет
+const severityNone = "NONE"
+
+func (vp *VulnPolicy) validate() error {
+	sev, ok := vulndb.ParseSeverity(vp.MaxSeverity)
+	if sev != severityNone && (!ok || sev == vulndb.SeverityUnknown) {
+		return fmt.Errorf("vulnerabilities.max_severity must be none, low, medium, high or critical, got %q", vp.MaxSeverity)
+	}
+	vp.MaxSeverity = sev
+	return nil
+}
+
+func (vp *VulnPolicy) ignored(f vulndb.Finding) bool {
+	for _, id := range vp.Ignore {
+		if strings.EqualFold(id, f.ID) {
+			return true
+		}
+		for _, alias := range f.Aliases {
+			if strings.EqualFold(id, alias) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// check: findings == nil — задача не сопоставлялась с базой уязвимостей,
+// и проверка проваливается, а не проходит молча.
+func (vp *VulnPolicy) check(findings []vulndb.Finding) CheckResult {
+	res := CheckResult{Check: CheckVulnerabilities}
+	if findings == nil {
+		res.Verdict, res.Violations = VerdictFail, []Violation{}
+		res.Error = "task was not matched against the vulnerability database"
+		return res
+	}
+	// уровень UNKNOWN имеет ранг 0 и проходит при любом max_severity, кроме none
+	limit := -1
+	if vp.MaxSeverity != severityNone {
+		limit = vulndb.SeverityRank(vp.MaxSeverity)
+	}
+	for _, f := range findings {
+		if vulndb.SeverityRank(f.Severity) <= limit || vp.ignored(f) {
+			continue
+		}
+		res.Violations = append(res.Violations, Violation{
+			Package: PackageRef{Name: f.Package.Name, Version: f.Package.Version, PURL: f.Package.PURL},
+			Detail:  f.ID + " (" + f.Severity + ")",
+		})
+	}
+	return res.done()
+}
+
+func (rq *Requirements) validate() error {
+	for _, f := range rq.Fields {
+		if packageFields[f] == nil {
+			return fmt.Errorf("require.fields: unknown field %q (want version, purl or type)", f)
+		}
+	}
+	return nil
+}
+
+func (rq *Requirements) checkLicenses(pkgs []sbom.Package) CheckResult {
+	res := CheckResult{Check: CheckKnownLicenses}
+	for _, p := range pkgs {
+		if !licenseKnown(p) {
+			res.Violations = append(res.Violations, Violation{Package: refOf(p), Detail: "license is unknown"})
+		}
+	}
+	return res.done()
+}
+
+func licenseKnown(p sbom.Package) bool {
+	for _, l := range p.Licenses {
+		v := strings.TrimSpace(l.SPDX())
+		if v != "" && !strings.EqualFold(v, noAssertion) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rq *Requirements) checkFields(pkgs []sbom.Package) CheckResult {
+	res := CheckResult{Check: CheckFields}
+	for _, p := range pkgs {
+		var missing []string
+		for _, f := range rq.Fields {
+			if strings.TrimSpace(packageFields[f](p)) == "" {
+				missing = append(missing, f)
+			}
+		}
+		if len(missing) > 0 {
+			res.Violations = append(res.Violations, Violation{
+				Package: refOf(p),
+				Detail:  "missing " + strings.Join(missing, ", "),
+			})
+		}
+	}
+	return res.done()
+}
+
+// done выставляет итог по найденным нарушениям.
+func (c CheckResult) done() CheckResult {
+	c.Verdict = VerdictPass
+	if len(c.Violations) > 0 {
+		c.Verdict = VerdictFail
+	} else {
+		c.Violations = []Violation{}
+	}
+	return c
+}
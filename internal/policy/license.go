@@ -0,0 +1,222 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"sbom-serv/internal/sbom"
+)
+
+// Action — что делать с лицензией.
+type Action string
+
+const (
+	ActionAllow  Action = "allow"
+	ActionReview Action = "review"
+	ActionDeny   Action = "deny"
+)
+
+func (a Action) rank() int {
+	switch a {
+	case ActionAllow:
+		return 0
+	case ActionReview:
+		return 1
+	}
+	return 2
+}
+
+func (a Action) valid() bool {
+	return a == ActionAllow || a == ActionReview || a == ActionDeny
+}
+
+// Итог проверки
+const (
+	VerdictPass   = "pass"
+	VerdictReview = "review"
+	VerdictFail   = "failed"
+)
+
+// Лицензия пакета неизвестна (SPDX NOASSERTION)
+const noAssertion = "NOASSERTION"
+
+// LicensePolicy — списки лицензий. Элемент списка — идентификатор SPDX
+// ("MIT"), идентификатор с исключением ("GPL-2.0-only WITH Classpath-exception-2.0")
+// или префикс со звёздочкой ("GPL-*"); регистр не важен. Точное совпадение
+// важнее префикса: можно разрешить "GPL-2.0-only WITH Classpath-exception-2.0",
+// запретив "GPL-*". При равных совпадениях действует самый строгий список.
+type LicensePolicy struct {
+	Allow  []string `json:"allow,omitempty" yaml:"allow"`
+	Review []string `json:"review,omitempty" yaml:"review"`
+	Deny   []string `json:"deny,omitempty" yaml:"deny"`
+	// Для лицензий не из списков и пакетов без лицензии; по умолчанию review
+	Default Action `json:"default,omitempty" yaml:"default"`
+}
+
+func (lp *LicensePolicy) validate() error {
+	if lp.Default == "" {
+		lp.Default = ActionReview
+	}
+	if !lp.Default.valid() {
+		return fmt.Errorf("licenses.default must be allow, review or deny, got %q", lp.Default)
+	}
+	for _, list := range [][]string{lp.Allow, lp.Review, lp.Deny} {
+		for _, p := range list {
+			if strings.TrimSpace(p) == "" || strings.Contains(strings.TrimSuffix(p, "*"), "*") {
+				return fmt.Errorf("licenses: invalid pattern %q", p)
+			}
+		}
+	}
+	return nil
+}
+
+// lookup — действие по спискам; ok=false — лицензии нет ни в одном.
+func (lp *LicensePolicy) lookup(license string) (Action, bool) {
+	for _, wildcard := range []bool{false, true} {
+		switch {
+		case matchAny(lp.Deny, license, wildcard):
+			return ActionDeny, true
+		case matchAny(lp.Review, license, wildcard):
+			return ActionReview, true
+		case matchAny(lp.Allow, license, wildcard):
+			return ActionAllow, true
+		}
+	}
+	return "", false
+}
+
+func matchAny(patterns []string, license string, wildcard bool) bool {
+	for _, p := range patterns {
+		prefix, isWildcard := strings.CutSuffix(p, "*")
+		switch {
+		case isWildcard != wildcard:
+		case !wildcard && strings.EqualFold(p, license):
+			return true
+		case wildcard && len(license) >= len(prefix) && strings.EqualFold(license[:len(prefix)], prefix):
+			return true
+		}
+	}
+	return false
+}
+
+// eval вычисляет действие для выражения: AND берёт самое строгое из частей,
+// OR — самое мягкое (можно выбрать любую из лицензий). offending — листья,
+// определившие результат.
+func (lp *LicensePolicy) eval(e *expr) (act Action, offending []string) {
+	switch e.op {
+	case "AND":
+		act = ActionAllow
+		for _, c := range e.children {
+			a, off := lp.eval(c)
+			switch {
+			case a.rank() > act.rank():
+				act, offending = a, off
+			case a == act:
+				offending = append(offending, off...)
+			}
+		}
+		return act, offending
+	case "OR":
+		act = ActionDeny
+		for i, c := range e.children {
+			a, off := lp.eval(c)
+			if i == 0 || a.rank() < act.rank() {
+				act, offending = a, off
+			}
+		}
+		return act, offending
+	}
+
+	a, ok := lp.lookup(e.String())
+	if !ok && e.with != "" {
+		a, ok = lp.lookup(e.license)
+	}
+	if !ok {
+		a = lp.Default
+	}
+	if a == ActionAllow {
+		return a, nil
+	}
+	return a, []string{e.String()}
+}
+
+// LicenseReport — результат проверки лицензий SBOM.
+type LicenseReport struct {
+	Verdict    string             `json:"verdict"`
+	Packages   int                `json:"packages"`
+	Violations []LicenseViolation `json:"violations"`
+}
+
+// LicenseViolation — пакет, лицензия которого запрещена или требует проверки.
+type LicenseViolation struct {
+	Action  Action `json:"action"`
+	License string `json:"license"`
+	// Лицензии из выражения, которые определили действие
+	Licenses []string   `json:"licenses"`
+	Package  PackageRef `json:"package"`
+}
+
+type PackageRef struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	PURL    string `json:"purl,omitempty"`
+}
+
+func refOf(p sbom.Package) PackageRef {
+	return PackageRef{Name: p.Name, Version: p.Version, PURL: p.PURL}
+}
+
+// Evaluate проверяет лицензии пакетов. Несколько лицензий одного пакета
+// считаются объединёнными через AND.
+func (lp *LicensePolicy) Evaluate(pkgs []sbom.Package) *LicenseReport {
+	rep := &LicenseReport{Verdict: VerdictPass, Packages: len(pkgs), Violations: []LicenseViolation{}}
+	worst := ActionAllow
+	for _, p := range pkgs {
+		e := packageLicense(p)
+		act, offending := lp.eval(e)
+		if act == ActionAllow {
+			continue
+		}
+		rep.Violations = append(rep.Violations, LicenseViolation{
+			Action:   act,
+			License:  e.String(),
+			Licenses: offending,
+			Package:  refOf(p),
+		})
+		if act.rank() > worst.rank() {
+			worst = act
+		}
+	}
+	switch worst {
+	case ActionDeny:
+		rep.Verdict = VerdictFail
+	case ActionReview:
+		rep.Verdict = VerdictReview
+	}
+	return rep
+}
+
+// packageLicense — выражение для всех лицензий пакета. Значения, которые
+// не разбираются как SPDX ("MIT, Apache-2.0", "BSD style"), остаются
+// отдельными лицензиями и сравниваются со списками как есть.
+func packageLicense(p sbom.Package) *expr {
+	var parts []*expr
+	for _, l := range p.Licenses {
+		raw := strings.TrimSpace(l.SPDX())
+		if raw == "" {
+			continue
+		}
+		e, err := parseSPDX(raw)
+		if err != nil {
+			e = &expr{license: raw}
+		}
+		parts = append(parts, e)
+	}
+	switch len(parts) {
+	case 0:
+		return &expr{license: noAssertion}
+	case 1:
+		return parts[0]
+	}
+	return &expr{op: "AND", children: parts}
+}
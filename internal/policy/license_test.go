@@ -0,0 +1,86 @@
+package policy
+
+import (
+	"slices"
+	"testing"
+
+	"sbom-serv/internal/sbom"
+)
+
+func testLicensePolicy(def Action) *LicensePolicy {
+	lp := &LicensePolicy{
+		Allow:   []string{"MIT", "Apache-2.0", "GPL-2.0-only WITH Classpath-exception-2.0"},
+		Review:  []string{"LGPL-*"},
+		Deny:    []string{"GPL-*", "AGPL-3.0-only"},
+		Default: def,
+	}
+	if err := lp.validate(); err != nil {
+		panic(err)
+	}
+	return lp
+}
+
+func TestLicenseEval(t *testing.T) {
+	lp := testLicensePolicy(ActionReview)
+	tests := []struct {
+		in        string
+		act       Action
+		offending []string
+	}{
+		{"MIT", ActionAllow, nil},
+		{"mit", ActionAllow, nil},
+		{"GPL-2.0-only", ActionDeny, []string{"GPL-2.0-only"}},
+		{"GPL-2.0+", ActionDeny, []string{"GPL-2.0+"}},
+		{"LGPL-2.1-or-later", ActionReview, []string{"LGPL-2.1-or-later"}},
+		// точное совпадение важнее префикса GPL-*
+		{"GPL-2.0-only WITH Classpath-exception-2.0", ActionAllow, nil},
+		{"gpl-2.0-only with classpath-exception-2.0", ActionAllow, nil},
+		{"GPL-3.0-only WITH GCC-exception-3.1", ActionDeny, []string{"GPL-3.0-only WITH GCC-exception-3.1"}},
+		// исключение не из списков — решает сама лицензия
+		{"Apache-2.0 WITH LLVM-exception", ActionAllow, nil},
+		{"MIT OR GPL-2.0-only AND Apache-2.0", ActionAllow, nil},
+		{"(MIT OR GPL-2.0-only) AND AGPL-3.0-only", ActionDeny, []string{"AGPL-3.0-only"}},
+		{"GPL-2.0-only OR LGPL-2.1-only", ActionReview, []string{"LGPL-2.1-only"}},
+		{"mit and apache-2.0", ActionAllow, nil},
+		{"GPL-2.0-only AND AGPL-3.0-only", ActionDeny, []string{"GPL-2.0-only", "AGPL-3.0-only"}},
+		{"MIT AND LGPL-3.0-only", ActionReview, []string{"LGPL-3.0-only"}},
+		{"BSD-3-Clause", ActionReview, []string{"BSD-3-Clause"}},
+	}
+	for _, tt := range tests {
+		e, err := parseSPDX(tt.in)
+		if err != nil {
+			t.Fatalf("parseSPDX(%q): %v", tt.in, err)
+		}
+		act, offending := lp.eval(e)
+		if act != tt.act || !slices.Equal(offending, tt.offending) {
+			t.Errorf("eval(%q) = %s %q, want %s %q", tt.in, act, offending, tt.act, tt.offending)
+		}
+	}
+}
+
+func TestPackageLicenseFallback(t *testing.T) {
+	tests := []struct {
+		licenses sbom.Licenses
+		def      Action
+		act      Action
+		license  string
+	}{
+		// не разбирается как SPDX — сравнивается со списками как есть
+		{sbom.Licenses{{Value: "BSD style"}}, ActionReview, ActionReview, "BSD style"},
+		{sbom.Licenses{{Value: "BSD style"}}, ActionDeny, ActionDeny, "BSD style"},
+		{sbom.Licenses{{Value: "MIT, Apache-2.0"}}, ActionAllow, ActionAllow, "MIT, Apache-2.0"},
+		{sbom.Licenses{{Value: "MIT"}, {Value: "BSD style"}}, ActionDeny, ActionDeny, "MIT AND BSD style"},
+		{sbom.Licenses{{Value: "Expat", Expression: "MIT"}}, ActionDeny, ActionAllow, "MIT"},
+		{nil, ActionReview, ActionReview, noAssertion},
+	}
+	for _, tt := range tests {
+		lp := testLicensePolicy(tt.def)
+		e := packageLicense(sbom.Package{Licenses: tt.licenses})
+		if got := e.String(); got != tt.license {
+			t.Errorf("packageLicense(%v) = %q, want %q", tt.licenses, got, tt.license)
+		}
+		if act, _ := lp.eval(e); act != tt.act {
+			t.Errorf("eval(%q) with default %s = %s, want %s", e.String(), tt.def, act, tt.act)
+		}
+	}
+}
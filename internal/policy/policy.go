@@ -0,0 +1,383 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"sbom-serv/internal/config"
+	"sbom-serv/internal/sbom"
+	"sbom-serv/internal/vulndb"
+)
+
+// Policy — политика проекта или арендатора (project == "").
+type Policy struct {
+	Tenant    string    `json:"tenant"`
+	Project   string    `json:"project,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Rules
+}
+
+// Rules — проверки политики; хранятся в sbom_policies.policy.
+type Rules struct {
+	Licenses        *LicensePolicy  `json:"licenses,omitempty" yaml:"licenses"`
+	Banned          []BannedPackage `json:"banned,omitempty" yaml:"banned"`
+	Vulnerabilities *VulnPolicy     `json:"vulnerabilities,omitempty" yaml:"vulnerabilities"`
+	Require         *Requirements   `json:"require,omitempty" yaml:"require"`
+}
+
+// Parse читает документ политики в YAML или JSON (JSON — подмножество YAML).
+func Parse(data []byte) (Rules, error) {
+	var r Rules
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.SetStrict(true)
+	if err := dec.Decode(&r); err != nil {
+		return r, fmt.Errorf("decode policy: %w", err)
+	}
+	return r, r.Validate()
+}
+
+func (r *Rules) Validate() error {
+	if r.Licenses == nil && len(r.Banned) == 0 && r.Vulnerabilities == nil && r.Require == nil {
+		return errors.New("policy is empty: nothing to check")
+	}
+	if r.Licenses != nil {
+		if err := r.Licenses.validate(); err != nil {
+			return err
+		}
+	}
+	for i := range r.Banned {
+		if err := r.Banned[i].validate(); err != nil {
+			return err
+		}
+	}
+	if r.Vulnerabilities != nil {
+		if err := r.Vulnerabilities.validate(); err != nil {
+			return err
+		}
+	}
+	if r.Require != nil {
+		return r.Require.validate()
+	}
+	return nil
+}
+
+// Report — результат проверки SBOM задачи.
+type Report struct {
+	// Проект политики; пусто — политика арендатора по умолчанию
+	Project string `json:"project,omitempty"`
+	// Общий итог: failed, если провалилась хотя бы одна проверка; review —
+	// лицензии требуют проверки, а остальное прошло
+	Verdict string `json:"verdict"`
+	// Проваленные проверки
+	Failed   []string       `json:"failed"`
+	Licenses *LicenseReport `json:"licenses,omitempty"`
+	Checks   []CheckResult  `json:"checks,omitempty"`
+}
+
+// Summary — краткий итог для ответа /scan/info: CI достаточно вердикта
+// и списка проваленных проверок.
+type Summary struct {
+	Verdict string   `json:"verdict"`
+	Failed  []string `json:"failed"`
+}
+
+func (rep *Report) Summary() Summary {
+	return Summary{Verdict: rep.Verdict, Failed: rep.Failed}
+}
+
+func (rep *Report) add(check, verdict string) {
+	switch verdict {
+	case VerdictFail:
+		rep.Failed = append(rep.Failed, check)
+		rep.Verdict = VerdictFail
+	case VerdictReview:
+		if rep.Verdict == VerdictPass {
+			rep.Verdict = VerdictReview
+		}
+	}
+}
+
+// Evaluate проверяет SBOM по политике. findings — находки задачи;
+// nil — задача не сопоставлялась с базой уязвимостей.
+func (p *Policy) Evaluate(doc *sbom.Document, findings []vulndb.Finding) *Report {
+	rep := &Report{Project: p.Project, Verdict: VerdictPass, Failed: []string{}}
+	if p.Licenses != nil {
+		rep.Licenses = p.Licenses.Evaluate(doc.Artifacts)
+		rep.add(CheckLicenses, rep.Licenses.Verdict)
+	}
+
+	var checks []CheckResult
+	if len(p.Banned) > 0 {
+		checks = append(checks, checkBanned(p.Banned, doc.Artifacts))
+	}
+	if p.Vulnerabilities != nil {
+		checks = append(checks, p.Vulnerabilities.check(findings))
+	}
+	if p.Require != nil && p.Require.KnownLicenses {
+		checks = append(checks, p.Require.checkLicenses(doc.Artifacts))
+	}
+	if p.Require != nil && len(p.Require.Fields) > 0 {
+		checks = append(checks, p.Require.checkFields(doc.Artifacts))
+	}
+	for _, c := range checks {
+		rep.add(c.Check, c.Verdict)
+	}
+	rep.Checks = checks
+	return rep
+}
+
+// Store хранит политики (sbom_policies) и результаты проверки задач (sbom_task_policy).
+type Store struct {
+	db    *sql.DB
+	vulns *vulndb.Store
+}
+
+func NewStore(db *sql.DB, vulns *vulndb.Store) *Store {
+	return &Store{db: db, vulns: vulns}
+}
+
+// Put создаёт или заменяет политику проекта арендатора.
+func (s *Store) Put(ctx context.Context, p Policy) error {
+	if !config.ValidTenant(p.Tenant) {
+		return fmt.Errorf("invalid tenant %q", p.Tenant)
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	doc, err := json.Marshal(p.Rules)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO sbom_policies(tenant_id, project, policy, updated_at)
+		VALUES ($1, $2, $3, now())
+		ON CONFLICT (tenant_id, project) DO UPDATE
+		SET policy = EXCLUDED.policy, updated_at = EXCLUDED.updated_at
+	`, p.Tenant, p.Project, string(doc))
+	return err
+}
+
+// Delete удаляет политику; sql.ErrNoRows — её не было.
+func (s *Store) Delete(ctx context.Context, tenant, project string) error {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM sbom_policies WHERE tenant_id = $1 AND project = $2
+	`, tenant, project)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// List — политики арендатора; tenant == "" — всех арендаторов.
+func (s *Store) List(ctx context.Context, tenant string) ([]Policy, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT tenant_id, project, policy, updated_at
+		FROM sbom_policies
+		WHERE $1 = '' OR tenant_id = $1
+		ORDER BY tenant_id, project
+	`, tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Policy{}
+	for rows.Next() {
+		p, err := scanPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPolicy(row rowScanner) (Policy, error) {
+	var p Policy
+	var doc []byte
+	var tenant, project string
+	var updated time.Time
+	if err := row.Scan(&tenant, &project, &doc, &updated); err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(doc, &p.Rules); err != nil {
+		return p, fmt.Errorf("policy %s/%s: %w", tenant, project, err)
+	}
+	p.Tenant, p.Project, p.UpdatedAt = tenant, project, updated
+	return p, nil
+}
+
+// For — политика для проекта: своя, иначе политика арендатора по умолчанию.
+// nil — политики нет.
+func (s *Store) For(ctx context.Context, tenant, project string) (*Policy, error) {
+	p, err := scanPolicy(s.db.QueryRowContext(ctx, `
+		SELECT tenant_id, project, policy, updated_at
+		FROM sbom_policies
+		WHERE tenant_id = $1 AND project IN ($2, '')
+		ORDER BY project DESC
+		LIMIT 1
+	`, tenant, project))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// EvaluateTask проверяет SBOM задачи по политике её проекта и сохраняет
+// результат, а итог — в sbom_tasks.policy_verdict. Если политики нет,
+// удаляет прежний результат и возвращает nil.
+func (s *Store) EvaluateTask(ctx context.Context, taskID string, doc *sbom.Document) (*Report, error) {
+	var tenant string
+	var project sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT tenant_id, project FROM sbom_tasks WHERE id = $1
+	`, taskID).Scan(&tenant, &project)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.For(ctx, tenant, project.String)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		// политику могли удалить после прошлой проверки
+		return nil, s.clearTask(ctx, taskID)
+	}
+
+	// этап policy идёт после matching, находки уже сохранены
+	var findings []vulndb.Finding
+	if p.Vulnerabilities != nil {
+		if findings, _, err = s.vulns.TaskFindings(ctx, taskID); err != nil {
+			return nil, err
+		}
+	}
+
+	rep := p.Evaluate(doc, findings)
+	data, err := json.Marshal(rep)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO sbom_task_policy(task_id, evaluated_at, report)
+		VALUES ($1, now(), $2)
+		ON CONFLICT (task_id) DO UPDATE
+		SET evaluated_at = EXCLUDED.evaluated_at, report = EXCLUDED.report
+	`, taskID, string(data))
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE sbom_tasks SET policy_verdict = $2 WHERE id = $1
+	`, taskID, rep.Verdict)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return rep, nil
+}
+
+func (s *Store) clearTask(ctx context.Context, taskID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sbom_task_policy WHERE task_id = $1`, taskID); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE sbom_tasks SET policy_verdict = NULL WHERE id = $1 AND policy_verdict IS NOT NULL
+	`, taskID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Reevaluate заново проверяет сохранённый SBOM завершённой задачи: после
+// изменения политики или повторного сопоставления с базой уязвимостей.
+func (s *Store) Reevaluate(ctx context.Context, paths config.UploadPaths, t vulndb.TaskRef) (*Report, error) {
+	doc, err := sbom.ReadFile(paths.Tenant(t.Tenant).ResultPath(t.ID))
+	if err != nil {
+		return nil, err
+	}
+	return s.EvaluateTask(ctx, t.ID, doc)
+}
+
+// DoneTasks — завершённые задачи арендатора для повторной проверки, страницами
+// по id; project == "" — все проекты. after — последний id предыдущей страницы.
+func (s *Store) DoneTasks(ctx context.Context, tenant, project, after string, limit int) ([]vulndb.TaskRef, error) {
+	if after == "" {
+		after = "00000000-0000-0000-0000-000000000000"
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id::text, tenant_id
+		FROM sbom_tasks
+		WHERE status = 'done'
+		  AND tenant_id = $1
+		  AND ($2 = '' OR project = $2)
+		  AND id > $3::uuid
+		ORDER BY id
+		LIMIT $4
+	`, tenant, project, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []vulndb.TaskRef
+	for rows.Next() {
+		var t vulndb.TaskRef
+		if err := rows.Scan(&t.ID, &t.Tenant); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// TaskReport — сохранённый результат проверки; nil — задача не проверялась.
+func (s *Store) TaskReport(ctx context.Context, taskID string) (*Report, *time.Time, error) {
+	var data []byte
+	var at time.Time
+	err := s.db.QueryRowContext(ctx, `
+		SELECT report, evaluated_at FROM sbom_task_policy WHERE task_id = $1
+	`, taskID).Scan(&data, &at)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var rep Report
+	if err := json.Unmarshal(data, &rep); err != nil {
+		return nil, nil, err
+	}
+	return &rep, &at, nil
+}
@@ -0,0 +1,170 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// expr — разобранное выражение SPDX: лист (лицензия, возможно с WITH)
+// или AND/OR над подвыражениями.
+type expr struct {
+	op       string // "", "AND" или "OR"
+	license  string // для листа: идентификатор, например GPL-2.0-or-later или Apache-2.0+
+	with     string // исключение после WITH
+	children []*expr
+}
+
+// String — лист в каноническом виде: "GPL-2.0-only WITH Classpath-exception-2.0".
+func (e *expr) String() string {
+	switch {
+	case e.op != "":
+		parts := make([]string, len(e.children))
+		for i, c := range e.children {
+			parts[i] = c.String()
+			if c.op != "" {
+				parts[i] = "(" + parts[i] + ")"
+			}
+		}
+		return strings.Join(parts, " "+e.op+" ")
+	case e.with != "":
+		return e.license + " WITH " + e.with
+	}
+	return e.license
+}
+
+// parseSPDX разбирает выражение лицензии SPDX (SPDX 2.3, приложение D).
+// Операторы принимаются в любом регистре: в метаданных пакетов
+// встречается "MIT or Apache-2.0".
+func parseSPDX(s string) (*expr, error) {
+	p := &spdxParser{tokens: tokenizeSPDX(s)}
+	if len(p.tokens) == 0 {
+		return nil, errors.New("empty license expression")
+	}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return e, nil
+}
+
+func tokenizeSPDX(s string) []string {
+	var out []string
+	for _, f := range strings.Fields(s) {
+		for f != "" {
+			i := strings.IndexAny(f, "()")
+			switch {
+			case i < 0:
+				out = append(out, f)
+				f = ""
+			case i > 0:
+				out = append(out, f[:i])
+				f = f[i:]
+			default:
+				out = append(out, f[:1])
+				f = f[1:]
+			}
+		}
+	}
+	return out
+}
+
+type spdxParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *spdxParser) peekOp(op string) bool {
+	return p.pos < len(p.tokens) && strings.EqualFold(p.tokens[p.pos], op)
+}
+
+func (p *spdxParser) or() (*expr, error) {
+	return p.binary("OR", p.and)
+}
+
+func (p *spdxParser) and() (*expr, error) {
+	return p.binary("AND", p.with)
+}
+
+func (p *spdxParser) binary(op string, next func() (*expr, error)) (*expr, error) {
+	first, err := next()
+	if err != nil {
+		return nil, err
+	}
+	e := &expr{op: op, children: []*expr{first}}
+	for p.peekOp(op) {
+		p.pos++
+		c, err := next()
+		if err != nil {
+			return nil, err
+		}
+		e.children = append(e.children, c)
+	}
+	if len(e.children) == 1 {
+		return first, nil
+	}
+	return e, nil
+}
+
+func (p *spdxParser) with() (*expr, error) {
+	e, err := p.atom()
+	if err != nil {
+		return nil, err
+	}
+	if !p.peekOp("WITH") {
+		return e, nil
+	}
+	if e.op != "" {
+		return nil, errors.New("WITH must follow a license identifier")
+	}
+	p.pos++
+	if p.pos >= len(p.tokens) || !isLicenseID(p.tokens[p.pos]) {
+		return nil, errors.New("missing exception after WITH")
+	}
+	e.with = p.tokens[p.pos]
+	p.pos++
+	return e, nil
+}
+
+func (p *spdxParser) atom() (*expr, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, errors.New("unexpected end of expression")
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+	if tok == "(" {
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.tokens) || p.tokens[p.pos] != ")" {
+			return nil, errors.New("missing )")
+		}
+		p.pos++
+		return e, nil
+	}
+	if !isLicenseID(tok) {
+		return nil, fmt.Errorf("unexpected %q", tok)
+	}
+	return &expr{license: tok}, nil
+}
+
+// isLicenseID: идентификатор SPDX или LicenseRef-..., допускается "+" в конце.
+func isLicenseID(tok string) bool {
+	switch strings.ToUpper(tok) {
+	case "AND", "OR", "WITH", "(", ")":
+		return false
+	}
+	for i, c := range strings.TrimSuffix(tok, "+") {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '.':
+		case c == ':' && i > 0: // DocumentRef-x:LicenseRef-y
+		default:
+			return false
+		}
+	}
+	return tok != "" && tok != "+"
+}
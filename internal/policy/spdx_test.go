@@ -0,0 +1,58 @@
+package policy
+
+import "testing"
+
+func TestParseSPDX(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"MIT", "MIT"},
+		{"(MIT)", "MIT"},
+		// AND связывает сильнее OR
+		{"MIT OR GPL-2.0-only AND Apache-2.0", "MIT OR (GPL-2.0-only AND Apache-2.0)"},
+		{"MIT AND GPL-2.0-only OR Apache-2.0", "(MIT AND GPL-2.0-only) OR Apache-2.0"},
+		{"(MIT OR GPL-2.0-only) AND Apache-2.0", "(MIT OR GPL-2.0-only) AND Apache-2.0"},
+		{"MIT OR Apache-2.0 OR BSD-3-Clause", "MIT OR Apache-2.0 OR BSD-3-Clause"},
+		{"(MIT OR(Apache-2.0 AND BSD-2-Clause))", "MIT OR (Apache-2.0 AND BSD-2-Clause)"},
+		{"GPL-2.0-only WITH Classpath-exception-2.0", "GPL-2.0-only WITH Classpath-exception-2.0"},
+		{"GPL-2.0-only WITH Classpath-exception-2.0 OR MIT", "GPL-2.0-only WITH Classpath-exception-2.0 OR MIT"},
+		{"mit or apache-2.0", "mit OR apache-2.0"},
+		{"MIT and BSD-3-Clause", "MIT AND BSD-3-Clause"},
+		{"gpl-2.0-only with classpath-exception-2.0", "gpl-2.0-only WITH classpath-exception-2.0"},
+		{"GPL-2.0+ OR LGPL-2.1+", "GPL-2.0+ OR LGPL-2.1+"},
+		{"LicenseRef-Proprietary", "LicenseRef-Proprietary"},
+		{"DocumentRef-spdx-tool-1.2:LicenseRef-MIT-Style-2", "DocumentRef-spdx-tool-1.2:LicenseRef-MIT-Style-2"},
+	}
+	for _, tt := range tests {
+		e, err := parseSPDX(tt.in)
+		if err != nil {
+			t.Errorf("parseSPDX(%q): %v", tt.in, err)
+			continue
+		}
+		if got := e.String(); got != tt.want {
+			t.Errorf("parseSPDX(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseSPDXErrors(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"   ",
+		"MIT AND",
+		"OR MIT",
+		"(MIT",
+		"MIT)",
+		"MIT Apache-2.0",
+		"MIT, Apache-2.0",
+		"BSD style",
+		"MIT WITH",
+		"(MIT OR BSD-3-Clause) WITH Classpath-exception-2.0",
+		"+",
+	} {
+		if e, err := parseSPDX(in); err == nil {
+			t.Errorf("parseSPDX(%q) = %q, want error", in, e.String())
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package project
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"sbom-serv/internal/logging"
+)
+
+// ErrNoSBOM — у версии нет SBOM: задачу удалили вручную или janitor.
+var ErrNoSBOM = errors.New("version has no SBOM")
+
+// Store ведёт проекты арендаторов (sbom_projects) и их версии
+// (sbom_project_versions). Проект появляется с первой успешной задачей,
+// загруженной с ?project=, версия — с ?project=&version=.
+type Store struct {
+	db  *sql.DB
+	log *slog.Logger
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db, log: logging.Component("project")}
+}
+
+// TaskFinished регистрирует завершённую задачу в её проекте; подходит для
+// worker.OnFinish. Ошибки только логируются: результат задачи уже сохранён,
+// пропущенные задачи дорегистрирует команда projects reindex.
+func (s *Store) TaskFinished(ctx context.Context, taskID string) {
+	if err := s.Record(ctx, taskID); err != nil {
+		s.log.Error("record project version", "task_id", taskID, "err", err)
+	}
+}
+
+// Record создаёт проект и версию успешной задачи и переводит версию на неё,
+// если у версии нет SBOM более новой задачи (по sbom_tasks.ts): повторная
+// или запоздалая регистрация старой задачи версию не откатывает. Задачи
+// без проекта и неуспешные пропускаются.
+func (s *Store) Record(ctx context.Context, taskID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var tenant, status string
+	var project, version sql.NullString
+	err = tx.QueryRowContext(ctx, `
+		SELECT tenant_id, status::text, project, project_version FROM sbom_tasks WHERE id = $1
+	`, taskID).Scan(&tenant, &status, &project, &version)
+	if err != nil {
+		return err
+	}
+	if status != "done" || !project.Valid {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO sbom_projects(tenant_id, name) VALUES ($1, $2)
+		ON CONFLICT (tenant_id, name) DO UPDATE SET updated_at = now()
+	`, tenant, project.String)
+	if err != nil {
+		return err
+	}
+	if version.Valid {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO sbom_project_versions(tenant_id, project, version, task_id) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (tenant_id, project, version) DO UPDATE
+			SET task_id = EXCLUDED.task_id, updated_at = now()
+			WHERE sbom_project_versions.task_id IS DISTINCT FROM EXCLUDED.task_id
+			  AND NOT EXISTS (
+			      SELECT 1 FROM sbom_tasks c, sbom_tasks n
+			      WHERE c.id = sbom_project_versions.task_id AND n.id = EXCLUDED.task_id
+			        AND c.ts > n.ts)
+		`, tenant, project.String, version.String, taskID)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// TaskRef — успешная задача проекта.
+type TaskRef struct {
+	ID      string
+	Tenant  string
+	Project string
+	// Пусто, если задача без версии
+	Version string
+}
+
+// DoneTask находит успешную задачу с проектом по id; sql.ErrNoRows — задачи
+// нет, она не завершена или загружена без проекта.
+func (s *Store) DoneTask(ctx context.Context, id string) (TaskRef, error) {
+	t := TaskRef{ID: id}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT tenant_id, project, COALESCE(project_version, '')
+		FROM sbom_tasks
+		WHERE id = $1 AND status = 'done' AND project IS NOT NULL
+	`, id).Scan(&t.Tenant, &t.Project, &t.Version)
+	return t, err
+}
+
+// Unrecorded — успешные задачи, которые Record ещё не учёл: нет проекта,
+// нет версии или у версии нет SBOM этой или более новой задачи. Страницами
+// по id: after — последний id предыдущей страницы.
+func (s *Store) Unrecorded(ctx context.Context, tenant, after string, limit int) ([]TaskRef, error) {
+	if after == "" {
+		after = "00000000-0000-0000-0000-000000000000"
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT t.id::text, t.tenant_id, t.project, COALESCE(t.project_version, '')
+		FROM sbom_tasks t
+		WHERE t.status = 'done'
+		  AND t.project IS NOT NULL
+		  AND ($1 = '' OR t.tenant_id = $1)
+		  AND t.id > $2::uuid
+		  AND (NOT EXISTS (SELECT 1 FROM sbom_projects p
+		                   WHERE p.tenant_id = t.tenant_id AND p.name = t.project)
+		       OR t.project_version IS NOT NULL AND NOT EXISTS (
+		           SELECT 1 FROM sbom_project_versions v
+		           JOIN sbom_tasks c ON c.id = v.task_id
+		           WHERE v.tenant_id = t.tenant_id AND v.project = t.project
+		             AND v.version = t.project_version AND c.ts >= t.ts))
+		ORDER BY t.id
+		LIMIT $3
+	`, tenant, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []TaskRef
+	for rows.Next() {
+		var t TaskRef
+		if err := rows.Scan(&t.ID, &t.Tenant, &t.Project, &t.Version); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+type Project struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	// Когда в проекте последний раз завершилась задача
+	UpdatedAt time.Time `json:"updated_at"`
+	Versions  int       `json:"versions"`
+	// Версия, SBOM которой обновлялся последним
+	LatestVersion *string `json:"latest_version,omitempty"`
+}
+
+// List — проекты арендатора по имени.
+func (s *Store) List(ctx context.Context, tenant string) ([]Project, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT p.name, p.created_at, p.updated_at,
+		       (SELECT count(*) FROM sbom_project_versions v
+		        WHERE v.tenant_id = p.tenant_id AND v.project = p.name),
+		       (SELECT v.version FROM sbom_project_versions v
+		        WHERE v.tenant_id = p.tenant_id AND v.project = p.name
+		        ORDER BY v.updated_at DESC LIMIT 1)
+		FROM sbom_projects p
+		WHERE p.tenant_id = $1
+		ORDER BY p.name
+	`, tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Project{}
+	for rows.Next() {
+		var p Project
+		var latest sql.NullString
+		if err := rows.Scan(&p.Name, &p.CreatedAt, &p.UpdatedAt, &p.Versions, &latest); err != nil {
+			return nil, err
+		}
+		if latest.Valid {
+			p.LatestVersion = &latest.String
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// Version — версия проекта и её текущий SBOM.
+type Version struct {
+	Version string `json:"version"`
+	// Задача с текущим SBOM; nil — задачу удалили
+	ZipID     *string   `json:"zip_id"`
+	CreatedAt time.Time `json:"created_at"`
+	// Когда версия последний раз получила новый SBOM
+	UpdatedAt time.Time `json:"updated_at"`
+	Packages  *int      `json:"packages,omitempty"`
+	Policy    *string   `json:"policy,omitempty"`
+}
+
+// Versions — история версий проекта, новые первыми; sql.ErrNoRows —
+// проекта нет.
+func (s *Store) Versions(ctx context.Context, tenant, project string) ([]Version, error) {
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM sbom_projects WHERE tenant_id = $1 AND name = $2)
+	`, tenant, project).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT v.version, v.task_id::text, v.created_at, v.updated_at, t.packages, t.policy_verdict
+		FROM sbom_project_versions v
+		LEFT JOIN sbom_tasks t ON t.id = v.task_id
+		WHERE v.tenant_id = $1 AND v.project = $2
+		ORDER BY v.created_at DESC, v.version DESC
+	`, tenant, project)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Version{}
+	for rows.Next() {
+		var v Version
+		var zipID, policy sql.NullString
+		var packages sql.NullInt64
+		if err := rows.Scan(&v.Version, &zipID, &v.CreatedAt, &v.UpdatedAt, &packages, &policy); err != nil {
+			return nil, err
+		}
+		if zipID.Valid {
+			v.ZipID = &zipID.String
+		}
+		if packages.Valid {
+			n := int(packages.Int64)
+			v.Packages = &n
+		}
+		if policy.Valid {
+			v.Policy = &policy.String
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// Current — задача с текущим SBOM версии проекта, а без версии — последняя
+// успешная задача проекта и её версия (пусто, если задача без версии).
+// sql.ErrNoRows — нет проекта, версии или успешных задач.
+func (s *Store) Current(ctx context.Context, tenant, project, version string) (taskID, taskVersion string, err error) {
+	if version != "" {
+		var id sql.NullString
+		err := s.db.QueryRowContext(ctx, `
+			SELECT task_id::text FROM sbom_project_versions
+			WHERE tenant_id = $1 AND project = $2 AND version = $3
+		`, tenant, project, version).Scan(&id)
+		if err != nil {
+			return "", "", err
+		}
+		if !id.Valid {
+			return "", "", ErrNoSBOM
+		}
+		return id.String, version, nil
+	}
+
+	var v sql.NullString
+	err = s.db.QueryRowContext(ctx, `
+		SELECT id::text, project_version FROM sbom_tasks
+		WHERE tenant_id = $1 AND project = $2 AND status = 'done'
+		ORDER BY ts DESC
+		LIMIT 1
+	`, tenant, project).Scan(&taskID, &v)
+	return taskID, v.String, err
+}
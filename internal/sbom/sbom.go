@@ -0,0 +1,127 @@
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Document — часть syft JSON, которая нужна сервису после сканирования.
+type Document struct {
+	Artifacts []Package `json:"artifacts"`
+}
+
+type Package struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Version  string   `json:"version"`
+	Type     string   `json:"type"`
+	PURL     string   `json:"purl"`
+	CPEs     CPEs     `json:"cpes"`
+	Licenses Licenses `json:"licenses"`
+}
+
+// License — лицензия пакета. Expression — выражение SPDX, если syft смог
+// его построить; иначе только исходное значение из метаданных пакета.
+type License struct {
+	Value      string `json:"value"`
+	Expression string `json:"spdxExpression"`
+}
+
+// SPDX возвращает выражение SPDX или, если его нет, исходное значение.
+func (l License) SPDX() string {
+	if l.Expression != "" {
+		return l.Expression
+	}
+	return l.Value
+}
+
+// Licenses читает оба формата syft: объекты (с v0.80) и строки (раньше).
+type Licenses []License
+
+// Expression — все лицензии пакета одной строкой через AND; порядок в SBOM
+// не важен, поэтому значения сортируются.
+func (ls Licenses) Expression() string {
+	parts := make([]string, 0, len(ls))
+	for _, l := range ls {
+		if v := strings.TrimSpace(l.SPDX()); v != "" {
+			parts = append(parts, v)
+		}
+	}
+	sort.Strings(parts)
+	if len(parts) > 1 {
+		for i, p := range parts {
+			if strings.Contains(p, " ") {
+				parts[i] = "(" + p + ")"
+			}
+		}
+	}
+	return strings.Join(parts, " AND ")
+}
+
+func (ls *Licenses) UnmarshalJSON(data []byte) error {
+	var objs []License
+	if err := json.Unmarshal(data, &objs); err == nil {
+		*ls = objs
+		return nil
+	}
+	var strs []string
+	if err := json.Unmarshal(data, &strs); err != nil {
+		return err
+	}
+	*ls = make(Licenses, 0, len(strs))
+	for _, s := range strs {
+		*ls = append(*ls, License{Value: s})
+	}
+	return nil
+}
+
+// CPEs читает оба формата syft: объекты {"cpe": ...} (новые версии) и строки.
+type CPEs []string
+
+func (cs *CPEs) UnmarshalJSON(data []byte) error {
+	var objs []struct {
+		CPE string `json:"cpe"`
+	}
+	if err := json.Unmarshal(data, &objs); err == nil {
+		*cs = make(CPEs, 0, len(objs))
+		for _, o := range objs {
+			*cs = append(*cs, o.CPE)
+		}
+		return nil
+	}
+	var strs []string
+	if err := json.Unmarshal(data, &strs); err != nil {
+		return err
+	}
+	*cs = strs
+	return nil
+}
+
+// BasePURL — purl без версии, квалификаторов и подпути: один и тот же
+// пакет в любой версии.
+func BasePURL(purl string) string {
+	if i := strings.IndexAny(purl, "?#"); i >= 0 {
+		purl = purl[:i]
+	}
+	if i := strings.LastIndex(purl, "@"); i > strings.LastIndex(purl, "/") {
+		purl = purl[:i]
+	}
+	return purl
+}
+
+func ReadFile(path string) (*Document, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var doc Document
+	if err := json.NewDecoder(f).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode syft json: %w", err)
+	}
+	return &doc, nil
+}
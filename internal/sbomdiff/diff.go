@@ -0,0 +1,233 @@
+package sbomdiff
+
+import (
+	"sort"
+
+	"sbom-serv/internal/sbom"
+	"sbom-serv/internal/vulndb"
+)
+
+// Diff — изменения между двумя SBOM: что стало в To по сравнению с From.
+type Diff struct {
+	From    Side            `json:"from"`
+	To      Side            `json:"to"`
+	Summary Summary         `json:"summary"`
+	Added   []Package       `json:"added"`
+	Removed []Package       `json:"removed"`
+	Changed []Change        `json:"changed"`
+	License []LicenseChange `json:"license_changes"`
+	// nil — одна из задач не сопоставлялась с базой уязвимостей
+	Vulns *VulnDiff `json:"vulnerabilities,omitempty"`
+}
+
+// Side — сравниваемая задача.
+type Side struct {
+	ZipID    string `json:"zip_id"`
+	Project  string `json:"project,omitempty"`
+	Packages int    `json:"packages"`
+}
+
+type Summary struct {
+	Added          int `json:"added"`
+	Removed        int `json:"removed"`
+	Changed        int `json:"changed"`
+	LicenseChanges int `json:"license_changes"`
+	NewVulns       int `json:"new_vulnerabilities"`
+	ResolvedVulns  int `json:"resolved_vulnerabilities"`
+}
+
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Type    string `json:"type"`
+	PURL    string `json:"purl,omitempty"`
+	License string `json:"license,omitempty"`
+}
+
+// Change — пакет, версия которого изменилась.
+type Change struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	From string `json:"from"`
+	To   string `json:"to"`
+	// upgrade, downgrade; пусто — версии равны по правилам сравнения (1.0 и 1.0.0)
+	Direction string `json:"direction,omitempty"`
+}
+
+// LicenseChange — у пакета изменилась лицензия. Version — версия в To.
+type LicenseChange struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Type    string `json:"type"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+}
+
+// VulnDiff — уязвимости, которых не было в From (New) и которых нет в To
+// (Resolved). Уязвимость одного и того же пакета в другой версии новой не считается.
+type VulnDiff struct {
+	New      []vulndb.Finding `json:"new"`
+	Resolved []vulndb.Finding `json:"resolved"`
+}
+
+// Compare сравнивает пакеты двух SBOM. Пакет определяется purl без версии,
+// а если purl нет — типом и именем. Если у пакета поменялась единственная
+// версия, это изменение; если версий несколько (npm допускает копии разных
+// версий), разница показывается как добавленные и удалённые версии.
+func Compare(from, to *sbom.Document) *Diff {
+	d := &Diff{
+		From:    Side{Packages: len(from.Artifacts)},
+		To:      Side{Packages: len(to.Artifacts)},
+		Added:   []Package{},
+		Removed: []Package{},
+		Changed: []Change{},
+		License: []LicenseChange{},
+	}
+	before, after := index(from.Artifacts), index(to.Artifacts)
+
+	keys := make(map[string]struct{}, len(before)+len(after))
+	for k := range before {
+		keys[k] = struct{}{}
+	}
+	for k := range after {
+		keys[k] = struct{}{}
+	}
+
+	for k := range keys {
+		fv, tv := before[k], after[k]
+		var onlyFrom, onlyTo []Package
+		for v, p := range fv {
+			if q, ok := tv[v]; ok {
+				d.licenseChange(p, q)
+				continue
+			}
+			onlyFrom = append(onlyFrom, p)
+		}
+		for v, q := range tv {
+			if _, ok := fv[v]; !ok {
+				onlyTo = append(onlyTo, q)
+			}
+		}
+
+		if len(onlyFrom) == 1 && len(onlyTo) == 1 {
+			p, q := onlyFrom[0], onlyTo[0]
+			c := Change{Name: q.Name, Type: q.Type, From: p.Version, To: q.Version}
+			switch vulndb.CompareVersions(q.Version, p.Version) {
+			case 1:
+				c.Direction = "upgrade"
+			case -1:
+				c.Direction = "downgrade"
+			}
+			d.Changed = append(d.Changed, c)
+			d.licenseChange(p, q)
+			continue
+		}
+		d.Removed = append(d.Removed, onlyFrom...)
+		d.Added = append(d.Added, onlyTo...)
+	}
+
+	sortPackages(d.Added)
+	sortPackages(d.Removed)
+	sort.Slice(d.Changed, func(i, j int) bool {
+		a, b := d.Changed[i], d.Changed[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Type < b.Type
+	})
+	sort.Slice(d.License, func(i, j int) bool {
+		a, b := d.License[i], d.License[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Version < b.Version
+	})
+
+	d.Summary.Added, d.Summary.Removed = len(d.Added), len(d.Removed)
+	d.Summary.Changed, d.Summary.LicenseChanges = len(d.Changed), len(d.License)
+	return d
+}
+
+func (d *Diff) licenseChange(p, q Package) {
+	if p.License != q.License {
+		d.License = append(d.License, LicenseChange{
+			Name: q.Name, Version: q.Version, Type: q.Type, From: p.License, To: q.License,
+		})
+	}
+}
+
+// CompareVulns добавляет в Diff разницу находок. Находки сравниваются по
+// идентификатору уязвимости и purl пакета без версии.
+func (d *Diff) CompareVulns(from, to []vulndb.Finding) {
+	key := func(f vulndb.Finding) string { return f.ID + "\x00" + sbom.BasePURL(f.Package.PURL) }
+	seen := func(list []vulndb.Finding) map[string]bool {
+		m := make(map[string]bool, len(list))
+		for _, f := range list {
+			m[key(f)] = true
+		}
+		return m
+	}
+	before, after := seen(from), seen(to)
+
+	v := &VulnDiff{New: []vulndb.Finding{}, Resolved: []vulndb.Finding{}}
+	for _, f := range to {
+		if !before[key(f)] {
+			v.New = append(v.New, f)
+		}
+	}
+	for _, f := range from {
+		if !after[key(f)] {
+			v.Resolved = append(v.Resolved, f)
+		}
+	}
+	sortFindings(v.New)
+	sortFindings(v.Resolved)
+	d.Vulns = v
+	d.Summary.NewVulns, d.Summary.ResolvedVulns = len(v.New), len(v.Resolved)
+}
+
+// index: ключ пакета -> версия -> пакет. Копии одной версии схлопываются.
+func index(pkgs []sbom.Package) map[string]map[string]Package {
+	out := make(map[string]map[string]Package)
+	for _, p := range pkgs {
+		k := p.Type + "\x00" + p.Name
+		if base := sbom.BasePURL(p.PURL); base != "" {
+			k = base
+		}
+		if out[k] == nil {
+			out[k] = make(map[string]Package)
+		}
+		out[k][p.Version] = Package{
+			Name:    p.Name,
+			Version: p.Version,
+			Type:    p.Type,
+			PURL:    p.PURL,
+			License: p.Licenses.Expression(),
+		}
+	}
+	return out
+}
+
+func sortPackages(list []Package) {
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return vulndb.CompareVersions(a.Version, b.Version) < 0
+	})
+}
+
+// sortFindings: сначала самые опасные.
+func sortFindings(list []vulndb.Finding) {
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if ra, rb := vulndb.SeverityRank(a.Severity), vulndb.SeverityRank(b.Severity); ra != rb {
+			return ra > rb
+		}
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		return a.Package.PURL < b.Package.PURL
+	})
+}
@@ -0,0 +1,97 @@
+package sbomdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Markdown — отчёт для комментария к pull request.
+func (d *Diff) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### SBOM diff: %s → %s\n\n", side(d.From), side(d.To))
+
+	s := d.Summary
+	if s.Added+s.Removed+s.Changed+s.LicenseChanges+s.NewVulns+s.ResolvedVulns == 0 {
+		b.WriteString("No changes.\n")
+		if d.Vulns == nil {
+			b.WriteString("\n_Vulnerabilities were not compared: a scan was not matched against the vulnerability database._\n")
+		}
+		return b.String()
+	}
+	fmt.Fprintf(&b, "**%d added, %d removed, %d changed, %d license changes", s.Added, s.Removed, s.Changed, s.LicenseChanges)
+	if d.Vulns != nil {
+		fmt.Fprintf(&b, ", %d new vulnerabilities, %d resolved", s.NewVulns, s.ResolvedVulns)
+	}
+	b.WriteString("**\n")
+
+	if len(d.Added) > 0 {
+		b.WriteString("\n#### Added\n\n| Package | Version | Type | License |\n|---|---|---|---|\n")
+		for _, p := range d.Added {
+			row(&b, p.Name, p.Version, p.Type, p.License)
+		}
+	}
+	if len(d.Removed) > 0 {
+		b.WriteString("\n#### Removed\n\n| Package | Version | Type | License |\n|---|---|---|---|\n")
+		for _, p := range d.Removed {
+			row(&b, p.Name, p.Version, p.Type, p.License)
+		}
+	}
+	if len(d.Changed) > 0 {
+		b.WriteString("\n#### Version changes\n\n| Package | Type | From | To |\n|---|---|---|---|\n")
+		for _, c := range d.Changed {
+			to := c.To
+			if c.Direction == "downgrade" {
+				to += " (downgrade)"
+			}
+			row(&b, c.Name, c.Type, c.From, to)
+		}
+	}
+	if len(d.License) > 0 {
+		b.WriteString("\n#### License changes\n\n| Package | Version | From | To |\n|---|---|---|---|\n")
+		for _, l := range d.License {
+			row(&b, l.Name, l.Version, l.From, l.To)
+		}
+	}
+
+	if d.Vulns == nil {
+		b.WriteString("\n_Vulnerabilities were not compared: a scan was not matched against the vulnerability database._\n")
+	} else {
+		if len(d.Vulns.New) > 0 {
+			b.WriteString("\n#### New vulnerabilities\n\n| ID | Severity | Package | Version | Fixed in |\n|---|---|---|---|---|\n")
+			for _, f := range d.Vulns.New {
+				row(&b, f.ID, f.Severity, f.Package.Name, f.Package.Version, strings.Join(f.FixedVersions, ", "))
+			}
+		}
+		if len(d.Vulns.Resolved) > 0 {
+			b.WriteString("\n#### Resolved vulnerabilities\n\n| ID | Severity | Package | Version |\n|---|---|---|---|\n")
+			for _, f := range d.Vulns.Resolved {
+				row(&b, f.ID, f.Severity, f.Package.Name, f.Package.Version)
+			}
+		}
+	}
+	return b.String()
+}
+
+func side(s Side) string {
+	if s.Project != "" {
+		return fmt.Sprintf("`%s` (%s)", s.ZipID, cell(s.Project))
+	}
+	return "`" + s.ZipID + "`"
+}
+
+func row(b *strings.Builder, cells ...string) {
+	b.WriteString("|")
+	for _, c := range cells {
+		b.WriteString(" " + cell(c) + " |")
+	}
+	b.WriteString("\n")
+}
+
+// cell экранирует значение для ячейки таблицы: имена и лицензии приходят из
+// метаданных пакетов и могут содержать "|" и переводы строк.
+func cell(s string) string {
+	if s == "" {
+		return "—"
+	}
+	return strings.NewReplacer("|", `\|`, "\r", " ", "\n", " ", "<", "&lt;", ">", "&gt;").Replace(s)
+}
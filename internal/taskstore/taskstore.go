@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 )
 
@@ -16,11 +17,88 @@ const (
 	StatusFailed  Status = "failed"
 )
 
+// Stage — этап обработки задачи внутри статуса queued/running.
+type Stage string
+
+const (
+	StageUploading  Stage = "uploading"
+	StageValidating Stage = "validating"
+	StageExtracting Stage = "extracting"
+	StageCataloging Stage = "cataloging"
+	StageConverting Stage = "converting"
+	StageMatching   Stage = "matching"
+	StagePolicy     Stage = "policy"
+	StageIndexing   Stage = "indexing"
+	StageStoring    Stage = "storing"
+)
+
+// AnyTenant снимает ограничение по арендатору. Только для внутренних
+// компонентов (воркер, janitor, webhook), но не для обработчиков запросов.
+const AnyTenant = ""
+
+var (
+	ErrQuotaExceeded = errors.New("tenant quota exceeded")
+	ErrNotActive     = errors.New("task is not queued or running")
+	ErrNotDone       = errors.New("task is not done")
+)
+
 type Task struct {
 	ID        string
+	Tenant    string
 	Status    Status
 	Timestamp time.Time
 	Error     *string
+	Stage     *Stage
+	Progress  *int
+	Packages  *int
+	Project   *string
+	// Версия проекта, для которой собран SBOM (например, тег релиза)
+	ProjectVersion *string
+	// Адрес для уведомления о завершении; секрет подписи из БД не читается
+	CallbackURL *string
+	// Кто создал задачу (auth.Identity.ClientID)
+	ClientID *string
+	// X-Request-ID запроса загрузки
+	RequestID *string
+	// W3C traceparent запроса загрузки
+	TraceParent *string
+	// Закреплённую задачу janitor не удаляет, а её SBOM сопоставляется
+	// заново при каждом обновлении базы уязвимостей
+	PinnedAt *time.Time
+	// Итог проверки SBOM по политике проекта (pass, review, fail);
+	// nil — политики не было
+	PolicyVerdict *string
+	// Сколько задача ждала в очереди; заполняется только ClaimNextQueued
+	QueueWait time.Duration
+}
+
+// Terminal сообщает, что задача больше не будет меняться воркером.
+func (t Task) Terminal() bool {
+	return t.Status == StatusDone || t.Status == StatusFailed
+}
+
+// NewTask — параметры новой задачи, известные на момент загрузки архива.
+type NewTask struct {
+	ID             string
+	Tenant         string
+	ClientID       string
+	Project        string
+	ProjectVersion string
+	CallbackURL    string
+	CallbackSecret string
+	RequestID      string
+	TraceParent    string
+}
+
+type ListFilter struct {
+	Tenant   string
+	ClientID string
+	Project  string
+	// Только queued/running
+	Active bool
+	// Только закреплённые
+	Pinned bool
+	Limit  int
 }
 
 type Store struct {
@@ -29,32 +107,164 @@ type Store struct {
 
 func New(db *sql.DB) *Store { return &Store{db: db} }
 
-func (s *Store) Enqueue(ctx context.Context, id string) error {
-	_, err := s.db.ExecContext(ctx, `
-		INSERT INTO sbom_tasks(id, status, ts, error)
-		VALUES ($1, 'queued', now(), NULL)
-	`, id)
-	return err
+const taskColumns = `id::text, tenant_id, status::text, ts, error, stage, progress, packages, project, project_version, callback_url, client_id, request_id, trace_parent, pinned_at, policy_verdict`
+
+type rowScanner interface {
+	Scan(dest ...any) error
 }
 
-func (s *Store) Get(ctx context.Context, id string) (Task, error) {
+func scanTask(row rowScanner) (Task, error) {
 	var t Task
-	var errNS sql.NullString
+	var errNS, stageNS, projectNS, versionNS, callbackNS, clientNS, requestNS, traceNS, policyNS sql.NullString
+	var progress, packages sql.NullInt64
+	var pinned sql.NullTime
 
-	err := s.db.QueryRowContext(ctx, `
-		SELECT id::text, status::text, ts, error
-		FROM sbom_tasks
-		WHERE id = $1
-	`, id).Scan(&t.ID, &t.Status, &t.Timestamp, &errNS)
+	err := row.Scan(&t.ID, &t.Tenant, &t.Status, &t.Timestamp, &errNS, &stageNS, &progress, &packages, &projectNS, &versionNS, &callbackNS, &clientNS, &requestNS, &traceNS, &pinned, &policyNS)
 	if err != nil {
 		return Task{}, err
 	}
 	if errNS.Valid {
 		t.Error = &errNS.String
 	}
+	if stageNS.Valid {
+		stage := Stage(stageNS.String)
+		t.Stage = &stage
+	}
+	if progress.Valid {
+		v := int(progress.Int64)
+		t.Progress = &v
+	}
+	if packages.Valid {
+		v := int(packages.Int64)
+		t.Packages = &v
+	}
+	if projectNS.Valid {
+		t.Project = &projectNS.String
+	}
+	if versionNS.Valid {
+		t.ProjectVersion = &versionNS.String
+	}
+	if callbackNS.Valid {
+		t.CallbackURL = &callbackNS.String
+	}
+	if clientNS.Valid {
+		t.ClientID = &clientNS.String
+	}
+	if requestNS.Valid {
+		t.RequestID = &requestNS.String
+	}
+	if traceNS.Valid {
+		t.TraceParent = &traceNS.String
+	}
+	if pinned.Valid {
+		t.PinnedAt = &pinned.Time
+	}
+	if policyNS.Valid {
+		t.PolicyVerdict = &policyNS.String
+	}
 	return t, nil
 }
 
+// Ключ advisory-блокировки (первая половина), под которой проверяется лимит
+// активных задач арендатора; вторая половина — hashtext(tenant_id).
+const quotaLockKey = 9876545
+
+// Create регистрирует задачу на время загрузки архива. Воркер её не берёт,
+// пока не будет вызван Enqueue. Лимит активных задач арендатора проверяется
+// в той же транзакции под блокировкой арендатора: одновременные загрузки
+// его не превысят. ErrQuotaExceeded — лимит исчерпан.
+func (s *Store) Create(ctx context.Context, nt NewTask) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1, hashtext($2))`, quotaLockKey, nt.Tenant); err != nil {
+		return err
+	}
+	if err := checkQuota(ctx, tx, nt.Tenant); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO sbom_tasks(id, tenant_id, status, ts, error, stage, project, callback_url, callback_secret, client_id, request_id, trace_parent, project_version)
+		VALUES ($1, $6, 'queued', now(), NULL, 'uploading', NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''))
+	`, nt.ID, nt.Project, nt.CallbackURL, nt.CallbackSecret, nt.ClientID, nt.Tenant, nt.RequestID, nt.TraceParent, nt.ProjectVersion)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Enqueue отдаёт загруженную задачу воркеру; sql.ErrNoRows — задачи нет
+// или она уже в очереди либо отменена.
+func (s *Store) Enqueue(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE sbom_tasks
+		SET stage = NULL, ts = now()
+		WHERE id = $1 AND status = 'queued' AND stage = 'uploading'
+	`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM sbom_tasks WHERE id = $1`, id)
+	return err
+}
+
+// Get возвращает задачу арендатора. Задача другого арендатора для
+// вызывающего не существует: sql.ErrNoRows.
+func (s *Store) Get(ctx context.Context, tenant, id string) (Task, error) {
+	return scanTask(s.db.QueryRowContext(ctx, `
+		SELECT `+taskColumns+`
+		FROM sbom_tasks
+		WHERE id = $1 AND ($2 = '' OR tenant_id = $2)
+	`, id, tenant))
+}
+
+func (s *Store) List(ctx context.Context, f ListFilter) ([]Task, error) {
+	limit := f.Limit
+	if limit <= 0 || limit > 1000 {
+		limit = 1000
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+taskColumns+`
+		FROM sbom_tasks
+		WHERE ($1 = '' OR project = $1)
+		  AND (NOT $2 OR status IN ('queued','running'))
+		  AND ($4 = '' OR client_id = $4)
+		  AND ($5 = '' OR tenant_id = $5)
+		  AND (NOT $6 OR pinned_at IS NOT NULL)
+		ORDER BY ts DESC
+		LIMIT $3
+	`, f.Project, f.Active, limit, f.ClientID, f.Tenant, f.Pinned)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []Task
+	for rows.Next() {
+		t, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
+
 func (s *Store) ClaimNextQueued(ctx context.Context) (Task, bool, error) {
 	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
 	if err != nil {
@@ -63,14 +273,15 @@ func (s *Store) ClaimNextQueued(ctx context.Context) (Task, bool, error) {
 	defer func() { _ = tx.Rollback() }()
 
 	var id string
+	var waited float64
 	err = tx.QueryRowContext(ctx, `
-		SELECT id::text
+		SELECT id::text, extract(epoch FROM now() - ts)::float8
 		FROM sbom_tasks
-		WHERE status = 'queued'
+		WHERE status = 'queued' AND stage IS NULL
 		ORDER BY ts ASC
 		FOR UPDATE SKIP LOCKED
 		LIMIT 1
-	`).Scan(&id)
+	`).Scan(&id, &waited)
 
 	if errors.Is(err, sql.ErrNoRows) {
 		_ = tx.Commit()
@@ -82,7 +293,8 @@ func (s *Store) ClaimNextQueued(ctx context.Context) (Task, bool, error) {
 
 	_, err = tx.ExecContext(ctx, `
 		UPDATE sbom_tasks
-		SET status = 'running', ts = now(), error = NULL
+		SET status = 'running', ts = now(), error = NULL,
+		    stage = NULL, progress = NULL, packages = NULL
 		WHERE id = $1
 	`, id)
 	if err != nil {
@@ -93,10 +305,11 @@ func (s *Store) ClaimNextQueued(ctx context.Context) (Task, bool, error) {
 		return Task{}, false, err
 	}
 
-	t, err := s.Get(ctx, id)
+	t, err := s.Get(ctx, AnyTenant, id)
 	if err != nil {
 		return Task{}, false, err
 	}
+	t.QueueWait = time.Duration(waited * float64(time.Second))
 	return t, true, nil
 }
 
@@ -110,3 +323,176 @@ func (s *Store) SetStatus(ctx context.Context, id string, status Status, errMsg
 	`, id, string(status), errMsg)
 	return err
 }
+
+// Finish завершает задачу воркера, только если она всё ещё running:
+// отменённую или перехваченную janitor задачу воркер не перезаписывает.
+func (s *Store) Finish(ctx context.Context, id string, status Status, errMsg *string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE sbom_tasks
+		SET status = $2::sbom_task_status,
+		    ts = now(),
+		    error = $3
+		WHERE id = $1 AND status = 'running'
+	`, id, string(status), errMsg)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Cancel переводит queued/running задачу арендатора в failed с указанной причиной.
+func (s *Store) Cancel(ctx context.Context, tenant, id, reason string) error {
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE sbom_tasks
+		SET status = 'failed', ts = now(), error = $3, progress = NULL
+		WHERE id = $1
+		  AND ($2 = '' OR tenant_id = $2)
+		  AND status IN ('queued','running')
+	`, id, tenant, reason)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+	if _, err := s.Get(ctx, tenant, id); err != nil {
+		return err
+	}
+	return ErrNotActive
+}
+
+// Pin закрепляет завершённую задачу арендатора или снимает закрепление.
+// Повторное закрепление сохраняет исходное время pinned_at.
+func (s *Store) Pin(ctx context.Context, tenant, id string, pinned bool) (Task, error) {
+	t, err := scanTask(s.db.QueryRowContext(ctx, `
+		UPDATE sbom_tasks
+		SET pinned_at = CASE WHEN $3 THEN COALESCE(pinned_at, now()) END
+		WHERE id = $1
+		  AND ($2 = '' OR tenant_id = $2)
+		  AND status = 'done'
+		RETURNING `+taskColumns, id, tenant, pinned))
+	if !errors.Is(err, sql.ErrNoRows) {
+		return t, err
+	}
+	if _, err := s.Get(ctx, tenant, id); err != nil {
+		return Task{}, err
+	}
+	return Task{}, ErrNotDone
+}
+
+// SetStage переводит задачу на новый этап и сбрасывает процент выполнения.
+// ts не трогаем: по нему janitor определяет зависшие running. Как и
+// SetProgress с SetPackages, меняет только running задачу: задачу, которую
+// janitor вернул в очередь, старый воркер трогать не должен.
+func (s *Store) SetStage(ctx context.Context, id string, stage Stage) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE sbom_tasks
+		SET stage = $2, progress = NULL
+		WHERE id = $1 AND status = 'running'
+	`, id, string(stage))
+	return err
+}
+
+func (s *Store) SetProgress(ctx context.Context, id string, percent int) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE sbom_tasks
+		SET progress = $2
+		WHERE id = $1 AND status = 'running'
+	`, id, percent)
+	return err
+}
+
+func (s *Store) SetPackages(ctx context.Context, id string, n int) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE sbom_tasks
+		SET packages = $2
+		WHERE id = $1 AND status = 'running'
+	`, id, n)
+	return err
+}
+
+// CheckQuota проверяет лимиты арендатора (sbom_tenants): одновременные задачи
+// и место в max_tasks, не занятое текущими SBOM версий проектов, —
+// до приёма архива, чтобы не загружать его зря. Окончательно лимит
+// проверяет Create. Арендатор без записи в sbom_tenants не ограничен.
+func (s *Store) CheckQuota(ctx context.Context, tenant string) error {
+	return checkQuota(ctx, s.db, tenant)
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func checkQuota(ctx context.Context, q queryRower, tenant string) error {
+	var maxActive, maxTasks sql.NullInt64
+	var active, protected int64
+	err := q.QueryRowContext(ctx, `
+		SELECT q.max_active_tasks,
+		       (SELECT count(*) FROM sbom_tasks WHERE tenant_id = $1 AND status IN ('queued','running')),
+		       q.max_tasks,
+		       (SELECT count(*) FROM sbom_protected_tasks p
+		        JOIN sbom_tasks t ON t.id = p.task_id
+		        WHERE p.tenant_id = $1 AND t.pinned_at IS NULL)
+		FROM sbom_tenants q
+		WHERE q.id = $1
+	`, tenant).Scan(&maxActive, &active, &maxTasks, &protected)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if maxActive.Valid && active >= maxActive.Int64 {
+		return fmt.Errorf("%w: too many active tasks", ErrQuotaExceeded)
+	}
+	// текущие SBOM версий проектов janitor не удаляет: если они заняли
+	// весь max_tasks, новая задача будет удалена сразу после завершения
+	if maxTasks.Valid && protected >= maxTasks.Int64 {
+		return fmt.Errorf("%w: project versions take all of max_tasks", ErrQuotaExceeded)
+	}
+	return nil
+}
+
+// QueueStats — число задач по статусам и возраст самой старой ожидающей задачи
+// арендатора (AnyTenant — всех арендаторов).
+type QueueStats struct {
+	Counts map[Status]int64
+	// Задачи, архив которых ещё загружается (status queued, stage uploading)
+	Uploading    int64
+	OldestQueued *time.Time
+}
+
+func (s *Store) QueueStats(ctx context.Context, tenant string) (QueueStats, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT status::text,
+		       count(*) FILTER (WHERE stage IS DISTINCT FROM 'uploading'),
+		       count(*) FILTER (WHERE stage = 'uploading'),
+		       min(ts) FILTER (WHERE status = 'queued' AND stage IS NULL)
+		FROM sbom_tasks
+		WHERE $1 = '' OR tenant_id = $1
+		GROUP BY status
+	`, tenant)
+	if err != nil {
+		return QueueStats{}, err
+	}
+	defer rows.Close()
+
+	st := QueueStats{Counts: map[Status]int64{
+		StatusQueued: 0, StatusRunning: 0, StatusDone: 0, StatusFailed: 0,
+	}}
+	for rows.Next() {
+		var status string
+		var n, uploading int64
+		var oldest sql.NullTime
+		if err := rows.Scan(&status, &n, &uploading, &oldest); err != nil {
+			return QueueStats{}, err
+		}
+		st.Counts[Status(status)] = n
+		st.Uploading += uploading
+		if oldest.Valid {
+			st.OldestQueued = &oldest.Time
+		}
+	}
+	return st, rows.Err()
+}
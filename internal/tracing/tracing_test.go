@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStdoutExporter(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "spans.json")
+	shutdown, err := Setup(context.Background(), Config{
+		Exporter:    "stdout",
+		File:        file,
+		SampleRatio: 1,
+		ServiceName: "sbom-serv-test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, parent := Start(context.Background(), "upload.save")
+	traceParent := TraceParent(ctx)
+	_, child := Start(ctx, "upload.enqueue")
+	child.End()
+	parent.End()
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	names := map[string]bool{}
+	for dec.More() {
+		var span struct {
+			Name        string
+			SpanContext struct{ TraceID string }
+			Resource    []struct {
+				Key   string
+				Value struct{ Value any }
+			}
+		}
+		if err := dec.Decode(&span); err != nil {
+			t.Fatalf("decode span: %v", err)
+		}
+		names[span.Name] = true
+		if !strings.Contains(traceParent, span.SpanContext.TraceID) {
+			t.Errorf("span %s: trace %s, want the one from %s", span.Name, span.SpanContext.TraceID, traceParent)
+		}
+		var service any
+		for _, kv := range span.Resource {
+			if kv.Key == "service.name" {
+				service = kv.Value.Value
+			}
+		}
+		if service != "sbom-serv-test" {
+			t.Errorf("span %s: service.name = %v", span.Name, service)
+		}
+	}
+	for _, want := range []string{"upload.save", "upload.enqueue"} {
+		if !names[want] {
+			t.Errorf("span %s was not exported; got %v", want, names)
+		}
+	}
+}
+
+func TestLinkTo(t *testing.T) {
+	const tp = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	link, ok := LinkTo(tp)
+	if !ok {
+		t.Fatal("valid traceparent was rejected")
+	}
+	if got := link.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace id = %s", got)
+	}
+	if _, ok := LinkTo("garbage"); ok {
+		t.Error("invalid traceparent was accepted")
+	}
+}
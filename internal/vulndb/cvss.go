@@ -0,0 +1,68 @@
+package vulndb
+
+import (
+	"math"
+	"strings"
+)
+
+// cvssScore вычисляет базовую оценку по вектору CVSS v3.x. Векторы v2 и v4
+// не поддерживаются: в OSV для них почти всегда есть и v3, и готовый уровень.
+func cvssScore(s SeverityScore) (float64, bool) {
+	if s.Type != "CVSS_V3" || !strings.HasPrefix(s.Score, "CVSS:3.") {
+		return 0, false
+	}
+	m := map[string]string{}
+	for _, part := range strings.Split(s.Score, "/")[1:] {
+		k, v, ok := strings.Cut(part, ":")
+		if !ok {
+			return 0, false
+		}
+		m[k] = v
+	}
+
+	changed := m["S"] == "C"
+	av, ok1 := pick(m["AV"], map[string]float64{"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2})
+	ac, ok2 := pick(m["AC"], map[string]float64{"L": 0.77, "H": 0.44})
+	prTable := map[string]float64{"N": 0.85, "L": 0.62, "H": 0.27}
+	if changed {
+		prTable = map[string]float64{"N": 0.85, "L": 0.68, "H": 0.5}
+	}
+	pr, ok3 := pick(m["PR"], prTable)
+	ui, ok4 := pick(m["UI"], map[string]float64{"N": 0.85, "R": 0.62})
+	cia := map[string]float64{"H": 0.56, "L": 0.22, "N": 0}
+	c, ok5 := pick(m["C"], cia)
+	i, ok6 := pick(m["I"], cia)
+	a, ok7 := pick(m["A"], cia)
+	if !(ok1 && ok2 && ok3 && ok4 && ok5 && ok6 && ok7) || (m["S"] != "U" && !changed) {
+		return 0, false
+	}
+
+	iss := 1 - (1-c)*(1-i)*(1-a)
+	impact := 6.42 * iss
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	if impact <= 0 {
+		return 0, true
+	}
+	exploitability := 8.22 * av * ac * pr * ui
+	if changed {
+		return roundUp(math.Min(1.08*(impact+exploitability), 10)), true
+	}
+	return roundUp(math.Min(impact+exploitability, 10)), true
+}
+
+func pick(v string, table map[string]float64) (float64, bool) {
+	f, ok := table[v]
+	return f, ok
+}
+
+// roundUp — округление вверх до десятых по спецификации CVSS 3.1
+// (без ошибок плавающей точки вроде 4.000000001 -> 4.1).
+func roundUp(x float64) float64 {
+	n := int64(math.Round(x * 100000))
+	if n%10000 == 0 {
+		return float64(n) / 100000
+	}
+	return float64(n/10000+1) / 10
+}
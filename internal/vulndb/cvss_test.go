@@ -0,0 +1,47 @@
+package vulndb
+
+import "testing"
+
+func TestCVSSScore(t *testing.T) {
+	tests := []struct {
+		score SeverityScore
+		want  float64
+		ok    bool
+	}{
+		{SeverityScore{"CVSS_V3", "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"}, 9.8, true},
+		{SeverityScore{"CVSS_V3", "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H"}, 10, true},
+		{SeverityScore{"CVSS_V3", "CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N"}, 6.1, true},
+		{SeverityScore{"CVSS_V3", "CVSS:3.1/AV:L/AC:L/PR:L/UI:N/S:U/C:H/I:H/A:H"}, 7.8, true},
+		{SeverityScore{"CVSS_V3", "CVSS:3.0/AV:N/AC:H/PR:N/UI:N/S:U/C:H/I:N/A:N"}, 5.9, true},
+		{SeverityScore{"CVSS_V3", "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:N"}, 0, true},
+		{SeverityScore{"CVSS_V2", "AV:N/AC:L/Au:N/C:P/I:P/A:P"}, 0, false},
+		{SeverityScore{"CVSS_V3", "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H"}, 0, false},
+		{SeverityScore{"CVSS_V3", "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:X/C:H/I:H/A:H"}, 0, false},
+		{SeverityScore{"CVSS_V3", "CVSS:3.1/AV:N/AC"}, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := cvssScore(tt.score)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("cvssScore(%q) = %v, %v; want %v, %v", tt.score.Score, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRoundUp(t *testing.T) {
+	tests := []struct {
+		x, want float64
+	}{
+		{0, 0},
+		{4, 4},
+		{4.02, 4.1},
+		{4.1, 4.1},
+		{4.000000001, 4},
+		{9.87, 9.9},
+		{10, 10},
+	}
+	for _, tt := range tests {
+		if got := roundUp(tt.x); got != tt.want {
+			t.Errorf("roundUp(%v) = %v, want %v", tt.x, got, tt.want)
+		}
+	}
+}
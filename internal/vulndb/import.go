@@ -0,0 +1,305 @@
+package vulndb
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Одна запись OSV редко больше сотни килобайт; ограничение защищает от
+// случайно попавших в каталог больших JSON-файлов.
+const maxAdvisorySize = 16 << 20
+
+// Сколько записей пишется в одной транзакции
+const importBatch = 500
+
+// ImportOptions — параметры импорта.
+type ImportOptions struct {
+	// Full перезаписывает все записи, даже если modified не изменился
+	Full bool
+	// Version — имя снимка; пусто — самый поздний modified в базе
+	Version string
+	// Warn получает сообщения о пропущенных файлах; nil — не сообщать
+	Warn func(msg string)
+}
+
+// ImportStats — итог импорта. Snapshot == nil — база не изменилась.
+// Added и Updated — записи, уже сохранённые в базе.
+type ImportStats struct {
+	Read      int       `json:"read"`
+	Added     int       `json:"added"`
+	Updated   int       `json:"updated"`
+	Unchanged int       `json:"unchanged"`
+	Invalid   int       `json:"invalid"`
+	Snapshot  *Snapshot `json:"snapshot,omitempty"`
+}
+
+// Snapshot — состояние базы после импорта, изменившего её.
+type Snapshot struct {
+	ID         int64     `json:"id"`
+	Version    string    `json:"version"`
+	Source     string    `json:"source"`
+	ImportedAt time.Time `json:"imported_at"`
+	Added      int       `json:"added"`
+	Updated    int       `json:"updated"`
+	Total      int       `json:"total"`
+}
+
+// Import загружает записи OSV из файлов и каталогов: *.json — одна запись,
+// *.zip — архив с записями (all.zip OSV по экосистеме). Каталоги обходятся
+// рекурсивно, так что подходит и выгрузка github/advisory-database.
+// Запись пропускается, если в базе уже есть версия с тем же или более
+// поздним modified; при изменениях создаётся новый снимок. Снимок
+// создаётся и при ошибке или прерывании, если часть пачек уже сохранена:
+// иначе задачи, сопоставленные с изменённой базой, не считались бы устаревшими.
+func (s *Store) Import(ctx context.Context, sources []string, opts ImportOptions) (ImportStats, error) {
+	var st ImportStats
+	known, err := s.modifiedIndex(ctx)
+	if err != nil {
+		return st, err
+	}
+
+	type record struct {
+		a       Advisory
+		raw     []byte
+		updated bool
+	}
+	var batch []record
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = tx.Rollback() }()
+		for _, r := range batch {
+			if err := put(ctx, tx, r.a, r.raw); err != nil {
+				return err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		for _, r := range batch {
+			if r.updated {
+				st.Updated++
+			} else {
+				st.Added++
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	warn := func(name string, err error) {
+		st.Invalid++
+		if opts.Warn != nil {
+			opts.Warn(fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	add := func(name string, raw []byte) error {
+		st.Read++
+		a, err := ParseAdvisory(raw)
+		if err == nil && a.Modified.IsZero() {
+			err = errors.New("decode osv: missing modified")
+		}
+		if err != nil {
+			warn(name, err)
+			return nil
+		}
+		prev, exists := known[a.ID]
+		if exists && !opts.Full && !a.Modified.After(prev) {
+			st.Unchanged++
+			return nil
+		}
+		// одна и та же запись может прийти из нескольких выгрузок (GHSA и OSV)
+		known[a.ID] = a.Modified
+		batch = append(batch, record{a: a, raw: raw, updated: exists})
+		if len(batch) >= importBatch {
+			return flush()
+		}
+		return nil
+	}
+
+	var importErr error
+	for _, src := range sources {
+		if importErr = walkSource(ctx, src, add, warn); importErr != nil {
+			break
+		}
+	}
+	if importErr == nil {
+		importErr = flush()
+	}
+	if st.Added+st.Updated == 0 {
+		return st, importErr
+	}
+
+	version := opts.Version
+	if importErr != nil && version != "" {
+		version += "-partial"
+	}
+	// при прерывании ctx уже отменён, а сохранённые пачки должны попасть в снимок
+	snap, err := s.recordSnapshot(context.WithoutCancel(ctx), version, strings.Join(sources, ","), st.Added, st.Updated)
+	if err != nil {
+		return st, errors.Join(importErr, err)
+	}
+	st.Snapshot = snap
+	return st, importErr
+}
+
+// walkSource передаёт в add содержимое каждого JSON-файла источника.
+func walkSource(ctx context.Context, src string, add func(name string, raw []byte) error, warn func(string, error)) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// .git выгрузки advisory-database
+			if path != src && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".json":
+			raw, err := readLimited(path)
+			if err != nil {
+				warn(path, err)
+				return nil
+			}
+			return add(path, raw)
+		case ".zip":
+			return walkZip(path, add, warn)
+		}
+		return nil
+	})
+}
+
+func walkZip(path string, add func(name string, raw []byte) error, warn func(string, error)) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !strings.EqualFold(filepath.Ext(f.Name), ".json") {
+			continue
+		}
+		name := path + ":" + f.Name
+		if f.UncompressedSize64 > maxAdvisorySize {
+			warn(name, errors.New("record too large"))
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			warn(name, err)
+			continue
+		}
+		raw, err := io.ReadAll(io.LimitReader(rc, maxAdvisorySize+1))
+		rc.Close()
+		if err == nil && len(raw) > maxAdvisorySize {
+			err = errors.New("record too large")
+		}
+		if err != nil {
+			warn(name, err)
+			continue
+		}
+		if err := add(name, raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readLimited(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	raw, err := io.ReadAll(io.LimitReader(f, maxAdvisorySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) > maxAdvisorySize {
+		return nil, errors.New("record too large")
+	}
+	return raw, nil
+}
+
+// modifiedIndex — modified всех записей базы, для инкрементального импорта.
+func (s *Store) modifiedIndex(ctx context.Context) (map[string]time.Time, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, modified FROM sbom_vulns`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string]time.Time{}
+	for rows.Next() {
+		var id string
+		var modified time.Time
+		if err := rows.Scan(&id, &modified); err != nil {
+			return nil, err
+		}
+		out[id] = modified
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) recordSnapshot(ctx context.Context, version, source string, added, updated int) (*Snapshot, error) {
+	snap := &Snapshot{Source: source, Added: added, Updated: updated}
+	var latest sql.NullTime
+	err := s.db.QueryRowContext(ctx, `
+		SELECT count(*), max(modified) FROM sbom_vulns
+	`).Scan(&snap.Total, &latest)
+	if err != nil {
+		return nil, err
+	}
+	snap.Version = version
+	if snap.Version == "" {
+		snap.Version = latest.Time.UTC().Format(time.RFC3339)
+	}
+
+	err = s.db.QueryRowContext(ctx, `
+		INSERT INTO sbom_vuln_snapshots(version, source, added, updated, total)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, imported_at
+	`, snap.Version, snap.Source, snap.Added, snap.Updated, snap.Total).Scan(&snap.ID, &snap.ImportedAt)
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// CurrentSnapshot — последний снимок базы; nil — импортов ещё не было.
+func (s *Store) CurrentSnapshot(ctx context.Context) (*Snapshot, error) {
+	var snap Snapshot
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, version, source, imported_at, added, updated, total
+		FROM sbom_vuln_snapshots
+		ORDER BY id DESC
+		LIMIT 1
+	`).Scan(&snap.ID, &snap.Version, &snap.Source, &snap.ImportedAt, &snap.Added, &snap.Updated, &snap.Total)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
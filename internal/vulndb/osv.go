@@ -0,0 +1,185 @@
+package vulndb
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Advisory — запись в формате OSV (https://ossf.github.io/osv-schema/).
+// Разбираются только поля, нужные для сопоставления и отчёта; исходный
+// JSON хранится в БД целиком.
+type Advisory struct {
+	ID               string            `json:"id"`
+	Modified         time.Time         `json:"modified"`
+	Published        *time.Time        `json:"published,omitempty"`
+	Withdrawn        *time.Time        `json:"withdrawn,omitempty"`
+	Aliases          []string          `json:"aliases,omitempty"`
+	Summary          string            `json:"summary,omitempty"`
+	Details          string            `json:"details,omitempty"`
+	Severity         []SeverityScore   `json:"severity,omitempty"`
+	Affected         []Affected        `json:"affected,omitempty"`
+	DatabaseSpecific *databaseSpecific `json:"database_specific,omitempty"`
+}
+
+type SeverityScore struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+type Affected struct {
+	Package           AffectedPackage   `json:"package"`
+	Ranges            []Range           `json:"ranges,omitempty"`
+	Versions          []string          `json:"versions,omitempty"`
+	Severity          []SeverityScore   `json:"severity,omitempty"`
+	EcosystemSpecific *databaseSpecific `json:"ecosystem_specific,omitempty"`
+}
+
+type AffectedPackage struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+	PURL      string `json:"purl,omitempty"`
+}
+
+type Range struct {
+	Type   string  `json:"type"`
+	Events []Event `json:"events"`
+}
+
+// Event — граница диапазона; заполнено ровно одно поле.
+type Event struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+	Limit        string `json:"limit,omitempty"`
+}
+
+// database_specific GHSA и некоторых других баз содержит готовую оценку
+type databaseSpecific struct {
+	Severity string `json:"severity,omitempty"`
+}
+
+// ParseAdvisory разбирает одну запись OSV.
+func ParseAdvisory(data []byte) (Advisory, error) {
+	var a Advisory
+	if err := json.Unmarshal(data, &a); err != nil {
+		return a, fmt.Errorf("decode osv: %w", err)
+	}
+	if a.ID == "" {
+		return a, fmt.Errorf("decode osv: missing id")
+	}
+	return a, nil
+}
+
+// Уровни критичности, от меньшего к большему
+const (
+	SeverityUnknown  = "UNKNOWN"
+	SeverityLow      = "LOW"
+	SeverityMedium   = "MEDIUM"
+	SeverityHigh     = "HIGH"
+	SeverityCritical = "CRITICAL"
+)
+
+var severityRank = map[string]int{
+	SeverityUnknown:  0,
+	SeverityLow:      1,
+	SeverityMedium:   2,
+	SeverityHigh:     3,
+	SeverityCritical: 4,
+}
+
+// SeverityRank — порядок уровня для сравнения; неизвестное значение — 0.
+func SeverityRank(s string) int {
+	return severityRank[strings.ToUpper(s)]
+}
+
+// ParseSeverity принимает уровень в любом регистре; MODERATE (GHSA) — это MEDIUM.
+func ParseSeverity(s string) (string, bool) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "MODERATE" {
+		s = SeverityMedium
+	}
+	_, ok := severityRank[s]
+	return s, ok
+}
+
+// Rating — уровень и оценка CVSS (0 — оценки нет). Готовый уровень из
+// database_specific важнее вычисленного по вектору: его выставил аналитик.
+func (a Advisory) Rating() (string, float64) {
+	score := 0.0
+	for _, s := range a.allScores() {
+		if v, ok := cvssScore(s); ok && v > score {
+			score = v
+		}
+	}
+
+	if a.DatabaseSpecific != nil {
+		if sev, ok := ParseSeverity(a.DatabaseSpecific.Severity); ok && sev != SeverityUnknown {
+			return sev, score
+		}
+	}
+	for _, af := range a.Affected {
+		if af.EcosystemSpecific == nil {
+			continue
+		}
+		if sev, ok := ParseSeverity(af.EcosystemSpecific.Severity); ok && sev != SeverityUnknown {
+			return sev, score
+		}
+	}
+	if score > 0 {
+		return severityFromScore(score), score
+	}
+	return SeverityUnknown, 0
+}
+
+func (a Advisory) allScores() []SeverityScore {
+	out := append([]SeverityScore(nil), a.Severity...)
+	for _, af := range a.Affected {
+		out = append(out, af.Severity...)
+	}
+	return out
+}
+
+// severityFromScore — качественная шкала CVSS v3.
+func severityFromScore(score float64) string {
+	switch {
+	case score >= 9:
+		return SeverityCritical
+	case score >= 7:
+		return SeverityHigh
+	case score >= 4:
+		return SeverityMedium
+	case score > 0:
+		return SeverityLow
+	}
+	return SeverityUnknown
+}
+
+// FixedVersions — версии с исправлением для пакета ecosystem/name.
+func (a Advisory) FixedVersions(ecosystem, name string) []string {
+	var out []string
+	for _, af := range a.Affected {
+		if !samePackage(af.Package, ecosystem, name) {
+			continue
+		}
+		for _, r := range af.Ranges {
+			for _, e := range r.Events {
+				if e.Fixed != "" && r.Type != "GIT" {
+					out = append(out, e.Fixed)
+				}
+			}
+		}
+	}
+	return out
+}
+
+func samePackage(p AffectedPackage, ecosystem, name string) bool {
+	return baseEcosystem(p.Ecosystem) == ecosystem && normalizeName(ecosystem, p.Name) == name
+}
+
+// baseEcosystem отбрасывает суффикс версии дистрибутива: "Debian:12" -> "Debian".
+func baseEcosystem(e string) string {
+	base, _, _ := strings.Cut(e, ":")
+	return base
+}
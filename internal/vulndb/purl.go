@@ -0,0 +1,73 @@
+package vulndb
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/package-url/packageurl-go"
+)
+
+// Тип purl -> экосистема OSV. Пакеты дистрибутивов (deb, rpm, apk) не
+// сопоставляются: OSV ведёт их по исходным пакетам и релизам дистрибутива,
+// а в архивах, которые сканирует сервис, их почти не бывает.
+var purlEcosystems = map[string]string{
+	"npm":      "npm",
+	"pypi":     "PyPI",
+	"maven":    "Maven",
+	"golang":   "Go",
+	"cargo":    "crates.io",
+	"gem":      "RubyGems",
+	"nuget":    "NuGet",
+	"composer": "Packagist",
+	"hex":      "Hex",
+	"pub":      "Pub",
+	"cran":     "CRAN",
+	"hackage":  "Hackage",
+	"swift":    "SwiftURL",
+}
+
+// pkgKey — пакет в терминах OSV: экосистема и нормализованное имя.
+type pkgKey struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+}
+
+// keyFromPURL возвращает ключ пакета и его версию. ok=false — экосистема
+// не поддерживается или purl не разбирается.
+func keyFromPURL(purl string) (pkgKey, string, bool) {
+	p, err := packageurl.FromString(purl)
+	if err != nil {
+		return pkgKey{}, "", false
+	}
+	eco, ok := purlEcosystems[p.Type]
+	if !ok {
+		return pkgKey{}, "", false
+	}
+
+	name := p.Name
+	switch p.Type {
+	case "maven":
+		if p.Namespace != "" {
+			name = p.Namespace + ":" + p.Name
+		}
+	case "npm", "golang", "composer", "swift":
+		if p.Namespace != "" {
+			name = p.Namespace + "/" + p.Name
+		}
+	}
+	return pkgKey{Ecosystem: eco, Name: normalizeName(eco, name)}, p.Version, true
+}
+
+var pypiSeparators = regexp.MustCompile(`[-_.]+`)
+
+// normalizeName приводит имя к виду, в котором оно хранится в sbom_vuln_packages.
+func normalizeName(ecosystem, name string) string {
+	switch ecosystem {
+	case "PyPI":
+		// PEP 503
+		return pypiSeparators.ReplaceAllString(strings.ToLower(name), "-")
+	case "NuGet", "Packagist", "npm":
+		return strings.ToLower(name)
+	}
+	return name
+}
@@ -0,0 +1,31 @@
+package vulndb
+
+import "testing"
+
+func TestKeyFromPURL(t *testing.T) {
+	tests := []struct {
+		purl    string
+		key     pkgKey
+		version string
+		ok      bool
+	}{
+		{"pkg:npm/lodash@4.17.20", pkgKey{"npm", "lodash"}, "4.17.20", true},
+		{"pkg:npm/%40Babel/Core@7.0.0", pkgKey{"npm", "@babel/core"}, "7.0.0", true},
+		{"pkg:pypi/Django_REST.framework@3.12", pkgKey{"PyPI", "django-rest-framework"}, "3.12", true},
+		{"pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1", pkgKey{"Maven", "org.apache.logging.log4j:log4j-core"}, "2.14.1", true},
+		{"pkg:golang/github.com/gin-gonic/gin@v1.7.0", pkgKey{"Go", "github.com/gin-gonic/gin"}, "v1.7.0", true},
+		{"pkg:cargo/serde@1.0.0", pkgKey{"crates.io", "serde"}, "1.0.0", true},
+		{"pkg:nuget/Newtonsoft.Json@12.0.1", pkgKey{"NuGet", "newtonsoft.json"}, "12.0.1", true},
+		{"pkg:composer/Symfony/HTTP-Kernel@5.0.0", pkgKey{"Packagist", "symfony/http-kernel"}, "5.0.0", true},
+		{"pkg:gem/rails", pkgKey{"RubyGems", "rails"}, "", true},
+		{"pkg:deb/debian/openssl@1.1.1", pkgKey{}, "", false},
+		{"not a purl", pkgKey{}, "", false},
+	}
+	for _, tt := range tests {
+		key, version, ok := keyFromPURL(tt.purl)
+		if key != tt.key || version != tt.version || ok != tt.ok {
+			t.Errorf("keyFromPURL(%q) = %+v, %q, %v; want %+v, %q, %v",
+				tt.purl, key, version, ok, tt.key, tt.version, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package vulndb
+
+import (
+	"context"
+
+	"sbom-serv/internal/config"
+	"sbom-serv/internal/sbom"
+)
+
+// TaskRef — завершённая задача, которую можно сопоставить заново.
+type TaskRef struct {
+	ID     string
+	Tenant string
+}
+
+// StaleFilter — какие завершённые задачи сопоставлять заново.
+type StaleFilter struct {
+	Tenant string
+	// Все задачи, а не только сопоставленные со старым снимком
+	All bool
+	// Только закреплённые
+	Pinned bool
+	// Только ни разу не сопоставленные: сопоставление при сканировании не удалось
+	Unmatched bool
+}
+
+// StaleTasks — завершённые задачи, сопоставленные не с последним снимком
+// базы или не сопоставлявшиеся вовсе. Список отдаётся страницами по id:
+// after — последний id предыдущей страницы.
+func (s *Store) StaleTasks(ctx context.Context, f StaleFilter, after string, limit int) ([]TaskRef, error) {
+	if after == "" {
+		after = "00000000-0000-0000-0000-000000000000"
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT t.id::text, t.tenant_id
+		FROM sbom_tasks t
+		WHERE t.status = 'done'
+		  AND ($1 = '' OR t.tenant_id = $1)
+		  AND t.id > $2::uuid
+		  AND ($3 OR t.vulns_matched_at IS NULL
+		       OR t.vulns_snapshot_id IS DISTINCT FROM (SELECT max(id) FROM sbom_vuln_snapshots))
+		  AND (NOT $4 OR t.pinned_at IS NOT NULL)
+		  AND (NOT $5 OR t.vulns_matched_at IS NULL)
+		ORDER BY t.id
+		LIMIT $6
+	`, f.Tenant, after, f.All, f.Pinned, f.Unmatched, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []TaskRef
+	for rows.Next() {
+		var t TaskRef
+		if err := rows.Scan(&t.ID, &t.Tenant); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// DoneTask находит завершённую задачу по id; sql.ErrNoRows — задачи нет
+// или она ещё не завершена.
+func (s *Store) DoneTask(ctx context.Context, id string) (TaskRef, error) {
+	t := TaskRef{ID: id}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT tenant_id FROM sbom_tasks WHERE id = $1 AND status = 'done'
+	`, id).Scan(&t.Tenant)
+	return t, err
+}
+
+// Rematch заново сопоставляет сохранённый SBOM задачи с текущей базой,
+// не запуская сканирование. Для закреплённой задачи новые находки
+// записываются в оповещения (MatchResult.Alerts).
+func (s *Store) Rematch(ctx context.Context, paths config.UploadPaths, t TaskRef) (MatchResult, error) {
+	doc, err := sbom.ReadFile(paths.Tenant(t.Tenant).ResultPath(t.ID))
+	if err != nil {
+		return MatchResult{}, err
+	}
+	return s.matchTask(ctx, t.ID, doc)
+}
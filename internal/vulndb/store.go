@@ -0,0 +1,419 @@
+package vulndb
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"sbom-serv/internal/sbom"
+)
+
+// Store — офлайн-база уязвимостей OSV в Postgres и найденные в задачах уязвимости.
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Put добавляет или заменяет запись. raw — исходный JSON записи.
+func (s *Store) Put(ctx context.Context, a Advisory, raw []byte) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := put(ctx, tx, a, raw); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func put(ctx context.Context, tx *sql.Tx, a Advisory, raw []byte) error {
+	severity, score := a.Rating()
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO sbom_vulns(id, modified, published, withdrawn, summary, severity, cvss_score, data)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, NULLIF($7, 0), $8)
+		ON CONFLICT (id) DO UPDATE
+		SET modified = EXCLUDED.modified, published = EXCLUDED.published,
+		    withdrawn = EXCLUDED.withdrawn, summary = EXCLUDED.summary,
+		    severity = EXCLUDED.severity, cvss_score = EXCLUDED.cvss_score, data = EXCLUDED.data
+	`, a.ID, a.Modified, a.Published, a.Withdrawn, a.Summary, severity, score, raw)
+	if err != nil {
+		return fmt.Errorf("put %s: %w", a.ID, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sbom_vuln_packages WHERE vuln_id = $1`, a.ID); err != nil {
+		return err
+	}
+	for _, af := range a.Affected {
+		eco := baseEcosystem(af.Package.Ecosystem)
+		if eco == "" || af.Package.Name == "" {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO sbom_vuln_packages(ecosystem, name, vuln_id)
+			VALUES ($1, $2, $3)
+			ON CONFLICT DO NOTHING
+		`, eco, normalizeName(eco, af.Package.Name), a.ID)
+		if err != nil {
+			return fmt.Errorf("put %s: %w", a.ID, err)
+		}
+	}
+	return nil
+}
+
+// Finding — уязвимость в конкретном пакете SBOM.
+type Finding struct {
+	ID            string         `json:"id"`
+	Aliases       []string       `json:"aliases"`
+	Summary       string         `json:"summary,omitempty"`
+	Severity      string         `json:"severity"`
+	CVSSScore     float64        `json:"cvss_score,omitempty"`
+	FixedVersions []string       `json:"fixed_versions"`
+	Package       FindingPackage `json:"package"`
+}
+
+type FindingPackage struct {
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	PURL      string `json:"purl"`
+	Ecosystem string `json:"ecosystem"`
+}
+
+// Match сопоставляет пакеты с базой. Пакеты без purl или из неподдерживаемых
+// экосистем пропускаются.
+func (s *Store) Match(ctx context.Context, pkgs []sbom.Package) ([]Finding, error) {
+	type candidate struct {
+		pkg     sbom.Package
+		key     pkgKey
+		version string
+	}
+	var cands []candidate
+	keys := map[pkgKey]struct{}{}
+	for _, p := range pkgs {
+		if p.PURL == "" {
+			continue
+		}
+		key, version, ok := keyFromPURL(p.PURL)
+		if !ok {
+			continue
+		}
+		if version == "" {
+			version = p.Version
+		}
+		cands = append(cands, candidate{pkg: p, key: key, version: version})
+		keys[key] = struct{}{}
+	}
+	if len(cands) == 0 {
+		return nil, nil
+	}
+
+	advisories, err := s.advisoriesFor(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Finding
+	seen := map[[2]string]bool{}
+	for _, c := range cands {
+		for _, a := range advisories[c.key] {
+			if seen[[2]string{a.ID, c.pkg.PURL}] || !a.affects(c.key, c.version) {
+				continue
+			}
+			seen[[2]string{a.ID, c.pkg.PURL}] = true
+
+			severity, score := a.Rating()
+			out = append(out, Finding{
+				ID:            a.ID,
+				Aliases:       nonNil(a.Aliases),
+				Summary:       a.Summary,
+				Severity:      severity,
+				CVSSScore:     score,
+				FixedVersions: nonNil(a.FixedVersions(c.key.Ecosystem, c.key.Name)),
+				Package: FindingPackage{
+					Name:      c.pkg.Name,
+					Version:   c.version,
+					PURL:      c.pkg.PURL,
+					Ecosystem: c.key.Ecosystem,
+				},
+			})
+		}
+	}
+	sortFindings(out)
+	return out, nil
+}
+
+func (a Advisory) affects(key pkgKey, version string) bool {
+	for _, af := range a.Affected {
+		if samePackage(af.Package, key.Ecosystem, key.Name) && af.affects(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// advisoriesFor загружает действующие записи для набора пакетов одним запросом.
+func (s *Store) advisoriesFor(ctx context.Context, keys map[pkgKey]struct{}) (map[pkgKey][]Advisory, error) {
+	list := make([]pkgKey, 0, len(keys))
+	for k := range keys {
+		list = append(list, k)
+	}
+	arg, err := json.Marshal(list)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT p.ecosystem, p.name, v.data
+		FROM jsonb_to_recordset($1::jsonb) AS k(ecosystem text, name text)
+		JOIN sbom_vuln_packages p ON p.ecosystem = k.ecosystem AND p.name = k.name
+		JOIN sbom_vulns v ON v.id = p.vuln_id
+		WHERE v.withdrawn IS NULL
+	`, string(arg))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[pkgKey][]Advisory{}
+	for rows.Next() {
+		var k pkgKey
+		var data []byte
+		if err := rows.Scan(&k.Ecosystem, &k.Name, &data); err != nil {
+			return nil, err
+		}
+		a, err := ParseAdvisory(data)
+		if err != nil {
+			return nil, err
+		}
+		out[k] = append(out[k], a)
+	}
+	return out, rows.Err()
+}
+
+// sortFindings: сначала самые опасные.
+func sortFindings(fs []Finding) {
+	slices.SortFunc(fs, func(a, b Finding) int {
+		return cmp.Or(
+			cmp.Compare(SeverityRank(b.Severity), SeverityRank(a.Severity)),
+			cmp.Compare(b.CVSSScore, a.CVSSScore),
+			cmp.Compare(a.ID, b.ID),
+			cmp.Compare(a.Package.PURL, b.Package.PURL),
+		)
+	})
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// MatchTask сопоставляет SBOM задачи с базой и заменяет её прежние находки.
+func (s *Store) MatchTask(ctx context.Context, taskID string, doc *sbom.Document) ([]Finding, error) {
+	res, err := s.matchTask(ctx, taskID, doc)
+	return res.Findings, err
+}
+
+// MatchResult — итог сопоставления задачи.
+type MatchResult struct {
+	Findings []Finding
+	// Находки, которых не было при прошлом сопоставлении; nil при первом
+	// сопоставлении — сравнивать не с чем
+	Added []Finding
+	// Новые находки закреплённой задачи, записанные в sbom_vuln_alerts;
+	// уязвимость, о которой уже сообщали, сюда не попадает
+	Alerts []Finding
+	// Снимок базы, с которым сопоставлялась задача; 0 — импортов не было
+	SnapshotID int64
+	Snapshot   string
+}
+
+func (s *Store) matchTask(ctx context.Context, taskID string, doc *sbom.Document) (MatchResult, error) {
+	var res MatchResult
+	// снимок читается до сопоставления: импорт, идущий параллельно, создаст
+	// новый снимок, и задача останется в списке на повторное сопоставление
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, version FROM sbom_vuln_snapshots ORDER BY id DESC LIMIT 1
+	`).Scan(&res.SnapshotID, &res.Snapshot)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return res, err
+	}
+	findings, err := s.Match(ctx, doc.Artifacts)
+	if err != nil {
+		return res, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return res, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// FOR UPDATE: одновременные сопоставления одной задачи идут по очереди
+	var matchedAt sql.NullTime
+	var pinned bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT vulns_matched_at, pinned_at IS NOT NULL FROM sbom_tasks WHERE id = $1 FOR UPDATE
+	`, taskID).Scan(&matchedAt, &pinned)
+	if err != nil {
+		return res, err
+	}
+	var added []Finding
+	if matchedAt.Valid {
+		prev, err := previousFindings(ctx, tx, taskID)
+		if err != nil {
+			return res, err
+		}
+		added = []Finding{}
+		for _, f := range findings {
+			if !prev[[2]string{f.ID, f.Package.PURL}] {
+				added = append(added, f)
+			}
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sbom_task_vulns WHERE task_id = $1`, taskID); err != nil {
+		return res, err
+	}
+	for _, f := range findings {
+		aliases, _ := json.Marshal(f.Aliases)
+		fixed, _ := json.Marshal(f.FixedVersions)
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO sbom_task_vulns(task_id, vuln_id, purl, package_name, package_version, ecosystem,
+			                            severity, cvss_score, aliases, fixed_versions, summary)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, 0), $9, $10, NULLIF($11, ''))
+		`, taskID, f.ID, f.Package.PURL, f.Package.Name, f.Package.Version, f.Package.Ecosystem,
+			f.Severity, f.CVSSScore, string(aliases), string(fixed), f.Summary)
+		if err != nil {
+			return res, err
+		}
+	}
+	// оповещения пишутся в той же транзакции: иначе после сбоя находка уже
+	// сохранена в sbom_task_vulns, и при следующем сопоставлении не считается новой
+	var alerts []Finding
+	if pinned {
+		if alerts, err = recordAlerts(ctx, tx, taskID, res.SnapshotID, added); err != nil {
+			return res, err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE sbom_tasks SET vulns_matched_at = now(), vulns_snapshot_id = NULLIF($2::bigint, 0) WHERE id = $1
+	`, taskID, res.SnapshotID); err != nil {
+		return res, err
+	}
+	if err := tx.Commit(); err != nil {
+		return res, err
+	}
+	res.Findings, res.Added, res.Alerts = findings, added, alerts
+	return res, nil
+}
+
+// recordAlerts сохраняет оповещения и возвращает находки, о которых ещё не
+// сообщали: уязвимость могла уже попасть в оповещения, исчезнуть из базы и вернуться.
+func recordAlerts(ctx context.Context, tx *sql.Tx, taskID string, snapshotID int64, findings []Finding) ([]Finding, error) {
+	var fresh []Finding
+	for _, f := range findings {
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO sbom_vuln_alerts(task_id, snapshot_id, vuln_id, purl, package_name, package_version,
+			                             severity, cvss_score, summary)
+			VALUES ($1, NULLIF($2::bigint, 0), $3, $4, $5, $6, $7, NULLIF($8, 0), NULLIF($9, ''))
+			ON CONFLICT (task_id, vuln_id, purl) DO NOTHING
+		`, taskID, snapshotID, f.ID, f.Package.PURL, f.Package.Name, f.Package.Version,
+			f.Severity, f.CVSSScore, f.Summary)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			fresh = append(fresh, f)
+		}
+	}
+	return fresh, nil
+}
+
+func previousFindings(ctx context.Context, tx *sql.Tx, taskID string) (map[[2]string]bool, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT vuln_id, purl FROM sbom_task_vulns WHERE task_id = $1`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[[2]string]bool{}
+	for rows.Next() {
+		var id, purl string
+		if err := rows.Scan(&id, &purl); err != nil {
+			return nil, err
+		}
+		out[[2]string{id, purl}] = true
+	}
+	return out, rows.Err()
+}
+
+// MatchInfo — когда и с каким снимком базы задача сопоставлялась.
+// Snapshot пуст, если в базе ещё не было ни одного импорта.
+type MatchInfo struct {
+	MatchedAt time.Time
+	Snapshot  string
+}
+
+// TaskFindings — сохранённые находки задачи. match == nil — задача
+// ещё не сопоставлялась.
+func (s *Store) TaskFindings(ctx context.Context, taskID string) (findings []Finding, match *MatchInfo, err error) {
+	var at sql.NullTime
+	var snapshot sql.NullString
+	err = s.db.QueryRowContext(ctx, `
+		SELECT t.vulns_matched_at, sn.version
+		FROM sbom_tasks t
+		LEFT JOIN sbom_vuln_snapshots sn ON sn.id = t.vulns_snapshot_id
+		WHERE t.id = $1
+	`, taskID).Scan(&at, &snapshot)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !at.Valid {
+		return nil, nil, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT vuln_id, purl, package_name, package_version, ecosystem,
+		       severity, COALESCE(cvss_score, 0), aliases, fixed_versions, COALESCE(summary, '')
+		FROM sbom_task_vulns
+		WHERE task_id = $1
+	`, taskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	findings = []Finding{}
+	for rows.Next() {
+		var f Finding
+		var aliases, fixed []byte
+		err := rows.Scan(&f.ID, &f.Package.PURL, &f.Package.Name, &f.Package.Version, &f.Package.Ecosystem,
+			&f.Severity, &f.CVSSScore, &aliases, &fixed, &f.Summary)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := json.Unmarshal(aliases, &f.Aliases); err != nil {
+			return nil, nil, err
+		}
+		if err := json.Unmarshal(fixed, &f.FixedVersions); err != nil {
+			return nil, nil, err
+		}
+		findings = append(findings, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	sortFindings(findings)
+	return findings, &MatchInfo{MatchedAt: at.Time, Snapshot: snapshot.String}, nil
+}
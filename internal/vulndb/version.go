@@ -0,0 +1,299 @@
+package vulndb
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Версии сравниваются одним правилом для всех экосистем: числовые части —
+// как числа, пре-релизы (dev, alpha, beta, rc, snapshot, ...) — раньше
+// релиза, post/sp — позже. Всё, что идёт после "-", — пре-релиз, в том
+// числе число (1.0.0-1 < 1.0.0), как в semver. Для semver, PEP 440 и Maven этого достаточно
+// на практике; перечисленные в записи OSV версии проверяются точным совпадением.
+
+type versionToken struct {
+	num  string // число без ведущих нулей; пусто — словесная часть
+	word string
+	pre  bool // перед частью стоит "-"
+}
+
+// Порядок словесных частей относительно релиза (0)
+var qualifierRank = map[string]int{
+	"dev":       -6,
+	"snapshot":  -5,
+	"alpha":     -4,
+	"a":         -4,
+	"beta":      -3,
+	"b":         -3,
+	"milestone": -2,
+	"m":         -2,
+	"rc":        -1,
+	"cr":        -1,
+	"c":         -1,
+	"pre":       -1,
+	"preview":   -1,
+	"final":     0,
+	"ga":        0,
+	"release":   0,
+	"post":      1,
+	"sp":        1,
+	"p":         1,
+}
+
+func (t versionToken) rank() int {
+	if r, ok := qualifierRank[t.word]; ok {
+		return r
+	}
+	// произвольная метка (1.0.0-next.1) — пре-релиз
+	return -1
+}
+
+func tokenizeVersion(v string) []versionToken {
+	v = strings.ToLower(strings.TrimSpace(v))
+	v = strings.TrimPrefix(v, "v")
+	// метаданные сборки (+build, локальная версия PEP 440) порядок не меняют
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		v = v[:i]
+	}
+
+	var out []versionToken
+	for i := 0; i < len(v); {
+		c := v[i]
+		switch {
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(v) && v[j] >= '0' && v[j] <= '9' {
+				j++
+			}
+			n := strings.TrimLeft(v[i:j], "0")
+			if n == "" {
+				n = "0"
+			}
+			out = append(out, versionToken{num: n, pre: i > 0 && v[i-1] == '-'})
+			i = j
+		case c >= 'a' && c <= 'z':
+			j := i
+			for j < len(v) && v[j] >= 'a' && v[j] <= 'z' {
+				j++
+			}
+			out = append(out, versionToken{word: v[i:j], pre: i > 0 && v[i-1] == '-'})
+			i = j
+		default:
+			i++
+		}
+	}
+	return out
+}
+
+func compareTokens(a, b versionToken) int {
+	switch {
+	case a.num != "" && b.num != "":
+		// 1.0.0-1 < 1.0.0.1
+		if a.pre != b.pre {
+			return cmpBool(b.pre, a.pre)
+		}
+		if len(a.num) != len(b.num) {
+			return cmpInt(len(a.num), len(b.num))
+		}
+		return strings.Compare(a.num, b.num)
+	case a.num != "":
+		// в пре-релизе semver числовая часть младше словесной
+		if a.pre && b.pre {
+			return -1
+		}
+		return 1
+	case b.num != "":
+		if a.pre && b.pre {
+			return 1
+		}
+		return -1
+	}
+	if r := cmpInt(a.rank(), b.rank()); r != 0 {
+		return r
+	}
+	return strings.Compare(a.word, b.word)
+}
+
+// CompareVersions сравнивает версии пакетов; возвращает -1, 0 или 1.
+func CompareVersions(a, b string) int {
+	ta, tb := tokenizeVersion(a), tokenizeVersion(b)
+	for i := 0; i < len(ta) && i < len(tb); i++ {
+		if r := compareTokens(ta[i], tb[i]); r != 0 {
+			return r
+		}
+	}
+	if len(ta) >= len(tb) {
+		return tailSign(ta[len(tb):])
+	}
+	return -tailSign(tb[len(ta):])
+}
+
+type versionCond struct {
+	op      string
+	version string
+}
+
+// VersionRange — диапазон версий: условия через запятую (">= 1.0, < 1.4"),
+// выполняться должны все. Версия без оператора — точное совпадение.
+type VersionRange []versionCond
+
+func ParseVersionRange(s string) (VersionRange, error) {
+	var r VersionRange
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		op := "="
+		for _, candidate := range []string{">=", "<=", "!=", ">", "<", "="} {
+			if rest, ok := strings.CutPrefix(part, candidate); ok {
+				op, part = candidate, strings.TrimSpace(rest)
+				break
+			}
+		}
+		if part == "" || strings.ContainsAny(part, " <>=!") {
+			return nil, fmt.Errorf("invalid version range %q", s)
+		}
+		r = append(r, versionCond{op: op, version: part})
+	}
+	return r, nil
+}
+
+// Contains проверяет версию; пустая версия не входит ни в один диапазон.
+func (r VersionRange) Contains(version string) bool {
+	if version == "" {
+		return false
+	}
+	for _, c := range r {
+		cmp := CompareVersions(version, c.version)
+		var ok bool
+		switch c.op {
+		case "=":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// tailSign — как хвост rest меняет версию: нули и "final" ничего не меняют
+// (1.0 == 1.0.0 == 1.0.Final), пре-релиз и число после "-" уменьшают,
+// остальное — увеличивает.
+func tailSign(rest []versionToken) int {
+	for _, t := range rest {
+		switch {
+		case t.num != "" && t.pre:
+			return -1
+		case t.num == "0", t.num == "" && t.rank() == 0:
+			continue
+		case t.num != "" || t.rank() > 0:
+			return 1
+		default:
+			return -1
+		}
+	}
+	return 0
+}
+
+func cmpBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// affects проверяет версию по диапазону OSV: события сортируются по версии,
+// introduced включает уязвимость, fixed и last_affected — выключают.
+func (r Range) affects(version string) bool {
+	if r.Type != "SEMVER" && r.Type != "ECOSYSTEM" {
+		// GIT-диапазоны задают коммиты, не версии пакетов
+		return false
+	}
+	events := slices.Clone(r.Events)
+	slices.SortStableFunc(events, func(a, b Event) int {
+		return compareEventVersions(eventVersion(a), eventVersion(b))
+	})
+
+	affected := false
+	for _, e := range events {
+		switch {
+		case e.Introduced != "":
+			if e.Introduced == "0" || CompareVersions(version, e.Introduced) >= 0 {
+				affected = true
+			}
+		case e.Fixed != "":
+			if CompareVersions(version, e.Fixed) >= 0 {
+				affected = false
+			}
+		case e.LastAffected != "":
+			if CompareVersions(version, e.LastAffected) > 0 {
+				affected = false
+			}
+		}
+	}
+	return affected
+}
+
+func eventVersion(e Event) string {
+	switch {
+	case e.Introduced != "":
+		return e.Introduced
+	case e.Fixed != "":
+		return e.Fixed
+	case e.LastAffected != "":
+		return e.LastAffected
+	}
+	return e.Limit
+}
+
+// "0" в introduced означает "с самой первой версии"
+func compareEventVersions(a, b string) int {
+	switch {
+	case a == "0" && b == "0":
+		return 0
+	case a == "0":
+		return -1
+	case b == "0":
+		return 1
+	}
+	return CompareVersions(a, b)
+}
+
+// affects — затронута ли версия пакета: явный список versions или диапазоны.
+func (af Affected) affects(version string) bool {
+	if version == "" {
+		return false
+	}
+	if slices.Contains(af.Versions, version) {
+		return true
+	}
+	for _, r := range af.Ranges {
+		if r.affects(version) {
+			return true
+		}
+	}
+	return false
+}
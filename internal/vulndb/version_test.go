@@ -0,0 +1,78 @@
+package vulndb
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.0", "1.0.0", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.0.Final", "1.0", 0},
+		{"1.0.0+build.5", "1.0.0", 0},
+		{"1.2.10", "1.2.9", 1},
+		{"1.02", "1.2", 0},
+		{"2.0.0", "10.0.0", -1},
+		{"1.0.0-alpha", "1.0.0", -1},
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-beta", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-rc.1", "1.0.0-beta", 1},
+		{"1.0.0-next.1", "1.0.0", -1},
+		{"1.0.0.dev1", "1.0.0", -1},
+		{"1.0.0.post1", "1.0.0", 1},
+		{"1.0-SNAPSHOT", "1.0", -1},
+		{"1.0-SP1", "1.0", 1},
+		{"1.0.1", "1.0.0-rc.1", 1},
+		{"1.0.0.1", "1.0.0", 1},
+		// числовой пре-релиз semver
+		{"1.0.0-1", "1.0.0", -1},
+		{"1.0.0-0", "1.0.0", -1},
+		{"1.0.0-1", "1.0.0-2", -1},
+		{"1.0.0-1", "1.0.0.1", -1},
+		{"1.0.0-1", "1.0.0-alpha", -1},
+		{"1.0.0-1", "0.9.9", 1},
+		{"v0.0.0-20210101000000-abcdef", "v0.0.0", -1},
+		{"v0.0.0-20210101000000-abcdef", "v0.0.0-20220101000000-abcdef", -1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := CompareVersions(tt.b, tt.a); got != -tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestRangeAffects(t *testing.T) {
+	tests := []struct {
+		name    string
+		r       Range
+		version string
+		want    bool
+	}{
+		{"from zero, before fix", Range{"SEMVER", []Event{{Introduced: "0"}, {Fixed: "1.2.0"}}}, "1.1.9", true},
+		{"from zero, fixed", Range{"SEMVER", []Event{{Introduced: "0"}, {Fixed: "1.2.0"}}}, "1.2.0", false},
+		{"prerelease of fix", Range{"SEMVER", []Event{{Introduced: "0"}, {Fixed: "1.2.0"}}}, "1.2.0-rc.1", true},
+		{"before introduced", Range{"ECOSYSTEM", []Event{{Introduced: "1.0"}, {Fixed: "1.5"}}}, "0.9", false},
+		{"introduced", Range{"ECOSYSTEM", []Event{{Introduced: "1.0"}, {Fixed: "1.5"}}}, "1.0", true},
+		{"last affected", Range{"ECOSYSTEM", []Event{{Introduced: "1.0"}, {LastAffected: "1.4"}}}, "1.4", true},
+		{"after last affected", Range{"ECOSYSTEM", []Event{{Introduced: "1.0"}, {LastAffected: "1.4"}}}, "1.4.1", false},
+		{"no fix", Range{"ECOSYSTEM", []Event{{Introduced: "2.0"}}}, "9.9", true},
+		{"unsorted events, second branch", Range{"SEMVER", []Event{
+			{Introduced: "2.0.0"}, {Fixed: "2.1.0"}, {Introduced: "0"}, {Fixed: "1.5.0"},
+		}}, "2.0.5", true},
+		{"unsorted events, between branches", Range{"SEMVER", []Event{
+			{Introduced: "2.0.0"}, {Fixed: "2.1.0"}, {Introduced: "0"}, {Fixed: "1.5.0"},
+		}}, "1.7.0", false},
+		{"git range", Range{"GIT", []Event{{Introduced: "0"}}}, "1.0.0", false},
+	}
+	for _, tt := range tests {
+		if got := tt.r.affects(tt.version); got != tt.want {
+			t.Errorf("%s: affects(%q) = %v, want %v", tt.name, tt.version, got, tt.want)
+		}
+	}
+}
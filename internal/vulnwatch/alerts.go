@@ -0,0 +1,80 @@
+package vulnwatch
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"sbom-serv/internal/vulndb"
+)
+
+// AlertRecord — сохранённое оповещение о новой уязвимости.
+type AlertRecord struct {
+	ID        int64                 `json:"id"`
+	TaskID    string                `json:"zip_id"`
+	Project   *string               `json:"project,omitempty"`
+	Snapshot  *string               `json:"snapshot,omitempty"`
+	VulnID    string                `json:"vuln_id"`
+	Severity  string                `json:"severity"`
+	CVSSScore float64               `json:"cvss_score,omitempty"`
+	Summary   string                `json:"summary,omitempty"`
+	Package   vulndb.FindingPackage `json:"package"`
+	CreatedAt time.Time             `json:"created_at"`
+}
+
+type AlertFilter struct {
+	Tenant  string
+	Project string
+	TaskID  string
+	Since   time.Time
+	Limit   int
+}
+
+// Alerts — оповещения арендатора, новые сначала.
+func (w *Watcher) Alerts(ctx context.Context, f AlertFilter) ([]AlertRecord, error) {
+	limit := f.Limit
+	if limit <= 0 || limit > 1000 {
+		limit = 1000
+	}
+	var since any
+	if !f.Since.IsZero() {
+		since = f.Since
+	}
+
+	rows, err := w.db.QueryContext(ctx, `
+		SELECT a.id, a.task_id::text, t.project, s.version, a.vuln_id, a.severity, COALESCE(a.cvss_score, 0),
+		       COALESCE(a.summary, ''), a.package_name, a.package_version, a.purl, a.created_at
+		FROM sbom_vuln_alerts a
+		JOIN sbom_tasks t ON t.id = a.task_id
+		LEFT JOIN sbom_vuln_snapshots s ON s.id = a.snapshot_id
+		WHERE ($1 = '' OR t.tenant_id = $1)
+		  AND ($2 = '' OR t.project = $2)
+		  AND ($3 = '' OR a.task_id::text = $3)
+		  AND ($4::timestamptz IS NULL OR a.created_at >= $4)
+		ORDER BY a.created_at DESC, a.id DESC
+		LIMIT $5
+	`, f.Tenant, f.Project, f.TaskID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []AlertRecord{}
+	for rows.Next() {
+		var a AlertRecord
+		var project, snapshot sql.NullString
+		err := rows.Scan(&a.ID, &a.TaskID, &project, &snapshot, &a.VulnID, &a.Severity, &a.CVSSScore,
+			&a.Summary, &a.Package.Name, &a.Package.Version, &a.Package.PURL, &a.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if project.Valid {
+			a.Project = &project.String
+		}
+		if snapshot.Valid {
+			a.Snapshot = &snapshot.String
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,208 @@
+package vulnwatch
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"sbom-serv/internal/config"
+	"sbom-serv/internal/logging"
+	"sbom-serv/internal/metrics"
+	"sbom-serv/internal/tracing"
+	"sbom-serv/internal/vulndb"
+)
+
+type Config struct {
+	// Как часто проверять, не обновилась ли база уязвимостей
+	Every time.Duration
+
+	// Сколько задач читать из БД за один запрос
+	BatchSize int
+
+	// Advisory lock key: закреплённые задачи обходит один инстанс
+	AdvisoryLockKey int64
+}
+
+// Alert — новые уязвимости закреплённой задачи после обновления базы.
+type Alert struct {
+	TaskID     string
+	Tenant     string
+	SnapshotID int64
+	Snapshot   string
+	Findings   []vulndb.Finding
+}
+
+// DedupKey — ключ уведомления об оповещении: одно на задачу и снимок.
+func (a Alert) DedupKey() string {
+	return "snapshot:" + strconv.FormatInt(a.SnapshotID, 10)
+}
+
+// Watcher заново сопоставляет SBOM закреплённых задач с базой уязвимостей,
+// когда появляется новый снимок, и сообщает о находках, которых раньше не было.
+type Watcher struct {
+	db        *sql.DB
+	vulns     *vulndb.Store
+	paths     config.UploadPaths
+	cfg       Config
+	log       *slog.Logger
+	onRematch []func(ctx context.Context, t vulndb.TaskRef)
+	onAlert   []func(ctx context.Context, a Alert)
+}
+
+func New(db *sql.DB, vulns *vulndb.Store, paths config.UploadPaths, cfg Config) *Watcher {
+	w := &Watcher{
+		db:    db,
+		vulns: vulns,
+		paths: paths,
+		cfg:   cfg,
+		log:   logging.Component("vulnwatch"),
+	}
+	if w.cfg.Every <= 0 {
+		w.cfg.Every = 10 * time.Minute
+	}
+	if w.cfg.BatchSize <= 0 {
+		w.cfg.BatchSize = 100
+	}
+	return w
+}
+
+// OnRematch регистрирует обработчик, вызываемый после сопоставления задачи
+// до оповещений (повторная проверка по политике).
+func (w *Watcher) OnRematch(fn func(ctx context.Context, t vulndb.TaskRef)) {
+	w.onRematch = append(w.onRematch, fn)
+}
+
+// OnAlert регистрирует обработчик новых уязвимостей.
+func (w *Watcher) OnAlert(fn func(ctx context.Context, a Alert)) {
+	w.onAlert = append(w.onAlert, fn)
+}
+
+func (w *Watcher) Start(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.Every)
+	defer ticker.Stop()
+
+	w.RunOnce(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.RunOnce(ctx)
+		}
+	}
+}
+
+// RunOnce сопоставляет закреплённые задачи, отставшие от последнего снимка,
+// и задачи, сопоставление которых при сканировании не удалось.
+// Возвращает false, если прогон не состоялся: ошибка БД или его сейчас
+// выполняет другой инстанс.
+func (w *Watcher) RunOnce(ctx context.Context) bool {
+	ctx, span := tracing.Start(ctx, "vulnwatch.run")
+	defer span.End()
+
+	conn, err := w.db.Conn(ctx)
+	if err != nil {
+		w.log.Error("db conn", "err", err)
+		span.SetStatus(codes.Error, err.Error())
+		return false
+	}
+	defer conn.Close()
+
+	var ok bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, w.cfg.AdvisoryLockKey).Scan(&ok); err != nil {
+		w.log.Error("try advisory lock", "err", err)
+		span.SetStatus(codes.Error, err.Error())
+		return false
+	}
+	span.SetAttributes(attribute.Bool("vulnwatch.lock_acquired", ok))
+	if !ok {
+		return false
+	}
+	defer func() {
+		_, _ = conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, w.cfg.AdvisoryLockKey)
+	}()
+
+	snap, err := w.vulns.CurrentSnapshot(ctx)
+	if err != nil {
+		w.log.Error("load snapshot", "err", err)
+		span.SetStatus(codes.Error, err.Error())
+		return false
+	}
+	if snap == nil {
+		return true
+	}
+
+	var rematched, alerted int
+	// закреплённые задачи, отставшие от снимка, и задачи, которые не удалось
+	// сопоставить при сканировании
+	for _, f := range []vulndb.StaleFilter{{Pinned: true}, {Unmatched: true}} {
+		after := ""
+		for {
+			page, err := w.vulns.StaleTasks(ctx, f, after, w.cfg.BatchSize)
+			if err != nil {
+				w.log.Error("list stale tasks", "err", err)
+				span.SetStatus(codes.Error, err.Error())
+				return false
+			}
+			for _, t := range page {
+				if ctx.Err() != nil {
+					return false
+				}
+				n, err := w.rematch(ctx, t)
+				if err != nil {
+					w.log.Error("rematch task", "task_id", t.ID, "tenant", t.Tenant, "err", err)
+					continue
+				}
+				rematched++
+				if n > 0 {
+					alerted++
+				}
+			}
+			if len(page) < w.cfg.BatchSize {
+				break
+			}
+			after = page[len(page)-1].ID
+		}
+	}
+
+	span.SetAttributes(attribute.Int("vulnwatch.tasks", rematched), attribute.Int("vulnwatch.alerted", alerted))
+	if rematched > 0 {
+		w.log.Info("tasks re-matched", "snapshot", snap.Version, "tasks", rematched, "alerted", alerted)
+	}
+	return true
+}
+
+// rematch возвращает число новых уязвимостей задачи.
+func (w *Watcher) rematch(ctx context.Context, t vulndb.TaskRef) (int, error) {
+	res, err := w.vulns.Rematch(ctx, w.paths, t)
+	if err != nil {
+		return 0, err
+	}
+	for _, fn := range w.onRematch {
+		fn(ctx, t)
+	}
+	if len(res.Alerts) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]string, 0, len(res.Alerts))
+	for _, f := range res.Alerts {
+		metrics.VulnAlerts.WithLabelValues(strings.ToLower(f.Severity)).Inc()
+		ids = append(ids, f.ID)
+	}
+	w.log.Warn("new vulnerabilities in pinned task", "task_id", t.ID, "tenant", t.Tenant,
+		"snapshot", res.Snapshot, "vulns", ids)
+
+	a := Alert{TaskID: t.ID, Tenant: t.Tenant, SnapshotID: res.SnapshotID, Snapshot: res.Snapshot, Findings: res.Alerts}
+	for _, fn := range w.onAlert {
+		fn(ctx, a)
+	}
+	return len(res.Alerts), nil
+}
@@ -0,0 +1,133 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress — callback_url ведёт на внутренний адрес.
+var ErrForbiddenAddress = errors.New("address is not allowed")
+
+// Сети, которые не считаются приватными в net/netip, но снаружи недоступны
+var internalNets = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // CGNAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+}
+
+// Guard не пускает уведомления на loopback, приватные, link-local и
+// неуказанные адреса: арендатор не должен через callback_url достучаться
+// до сервисов внутри сети. Адрес проверяется при загрузке и ещё раз при
+// каждом соединении, поэтому подмена DNS-ответа между ними не поможет.
+// Внутренних получателей разрешают явно: имена хостов, IP и подсети.
+type Guard struct {
+	hosts    map[string]bool
+	nets     []netip.Prefix
+	resolver *net.Resolver
+}
+
+// NewGuard: allowed — имена хостов ("hooks.ci.svc.cluster.local"), адреса
+// и подсети ("10.20.0.0/16"), на которые можно слать уведомления.
+func NewGuard(allowed []string) *Guard {
+	g := &Guard{hosts: make(map[string]bool), resolver: net.DefaultResolver}
+	for _, a := range allowed {
+		a = strings.TrimSpace(a)
+		if p, err := netip.ParsePrefix(a); err == nil {
+			g.nets = append(g.nets, p.Masked())
+			continue
+		}
+		if ip, err := netip.ParseAddr(a); err == nil {
+			g.nets = append(g.nets, netip.PrefixFrom(ip.Unmap(), ip.Unmap().BitLen()))
+			continue
+		}
+		g.hosts[strings.ToLower(a)] = true
+	}
+	return g
+}
+
+// CheckURL проверяет callback_url: схему, хост и все его адреса.
+func (g *Guard) CheckURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("scheme must be http or https")
+	}
+	host := u.Hostname()
+	if host == "" {
+		return errors.New("missing host")
+	}
+	if g.hosts[strings.ToLower(host)] {
+		return nil
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return g.checkAddr(ip)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	addrs, err := g.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("cannot resolve host %s", host)
+	}
+	for _, ip := range addrs {
+		if err := g.checkAddr(ip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DialContext — для http.Transport: адрес проверяется после разрешения
+// имени, непосредственно перед соединением.
+func (g *Guard) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if host, _, err := net.SplitHostPort(addr); err != nil || !g.hosts[strings.ToLower(host)] {
+		d.Control = g.control
+	}
+	return d.DialContext(ctx, network, addr)
+}
+
+func (g *Guard) control(_, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	return g.checkAddr(ap.Addr())
+}
+
+func (g *Guard) checkAddr(ip netip.Addr) error {
+	ip = ip.Unmap()
+	for _, p := range g.nets {
+		if p.Contains(ip) {
+			return nil
+		}
+	}
+	if internalAddr(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, ip)
+	}
+	return nil
+}
+
+func internalAddr(ip netip.Addr) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, p := range internalNets {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+const (
+	HeaderEvent     = "X-SBOM-Event"
+	HeaderDelivery  = "X-SBOM-Delivery"
+	HeaderTimestamp = "X-SBOM-Timestamp"
+	HeaderSignature = "X-SBOM-Signature"
+)
+
+// Sign считает подпись запроса: HMAC-SHA256 от "<timestamp>.<body>".
+// Получатель должен сравнить её с заголовком X-SBOM-Signature и отбросить
+// запросы со слишком старым X-SBOM-Timestamp.
+func Sign(secret []byte, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify — проверка подписи на стороне получателя.
+func Verify(secret []byte, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
@@ -0,0 +1,465 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"sbom-serv/internal/logging"
+	"sbom-serv/internal/taskstore"
+	"sbom-serv/internal/vulndb"
+)
+
+var (
+	ErrNoCallback  = errors.New("task has no callback_url")
+	ErrNotFinished = errors.New("task is not finished")
+)
+
+type Config struct {
+	// Как часто искать доставки, которые пора отправить
+	Every time.Duration
+
+	// Сколько попыток делать до перевода доставки в failed
+	MaxAttempts int
+
+	// Задержка перед повтором: BaseBackoff * 2^(attempt-1), но не больше MaxBackoff
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+
+	// Таймаут одного HTTP-запроса
+	Timeout time.Duration
+
+	// Сколько доставок забирать за один прогон
+	BatchSize int
+
+	// Внутренние получатели: имена хостов, адреса и подсети (см. Guard)
+	AllowedHosts []string
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Every:       5 * time.Second,
+		MaxAttempts: 10,
+		BaseBackoff: 10 * time.Second,
+		MaxBackoff:  1 * time.Hour,
+		Timeout:     10 * time.Second,
+		BatchSize:   20,
+	}
+}
+
+type Delivery struct {
+	ID             string          `json:"id"`
+	TaskID         string          `json:"task_id"`
+	Event          string          `json:"event"`
+	URL            string          `json:"url"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code,omitempty"`
+	LastError      *string         `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+// Payload — тело уведомления.
+type Payload struct {
+	Event      string    `json:"event"`
+	DeliveryID string    `json:"delivery_id"`
+	Task       TaskInfo  `json:"task"`
+	CreatedAt  time.Time `json:"created_at"`
+
+	// Только для task.vulnerabilities: снимок базы и новые находки
+	Snapshot        string           `json:"snapshot,omitempty"`
+	Vulnerabilities []vulndb.Finding `json:"vulnerabilities,omitempty"`
+}
+
+// EventVulnerabilities — в закреплённой задаче найдены новые уязвимости.
+const EventVulnerabilities = "task.vulnerabilities"
+
+type TaskInfo struct {
+	ZipID    string  `json:"zip_id"`
+	Status   string  `json:"status"`
+	Error    *string `json:"error,omitempty"`
+	Project  *string `json:"project,omitempty"`
+	Version  *string `json:"version,omitempty"`
+	Packages *int    `json:"packages,omitempty"`
+	// Итог проверки по политике: pass, review или fail
+	Policy *string   `json:"policy,omitempty"`
+	TS     time.Time `json:"ts"`
+}
+
+// Dispatcher хранит доставки в sbom_webhook_deliveries и отправляет их
+// с повторами. Несколько реплик могут работать одновременно: доставка
+// захватывается на время отправки через FOR UPDATE SKIP LOCKED.
+type Dispatcher struct {
+	db     *sql.DB
+	store  *taskstore.Store
+	client *http.Client
+	guard  *Guard
+	cfg    Config
+	log    *slog.Logger
+}
+
+func New(db *sql.DB, store *taskstore.Store, cfg Config) *Dispatcher {
+	d := &Dispatcher{
+		db:    db,
+		store: store,
+		cfg:   cfg,
+		log:   logging.Component("webhook"),
+	}
+	if d.cfg.Every <= 0 {
+		d.cfg.Every = 5 * time.Second
+	}
+	if d.cfg.MaxAttempts <= 0 {
+		d.cfg.MaxAttempts = 10
+	}
+	if d.cfg.BaseBackoff <= 0 {
+		d.cfg.BaseBackoff = 10 * time.Second
+	}
+	if d.cfg.MaxBackoff < d.cfg.BaseBackoff {
+		d.cfg.MaxBackoff = d.cfg.BaseBackoff
+	}
+	if d.cfg.Timeout <= 0 {
+		d.cfg.Timeout = 10 * time.Second
+	}
+	if d.cfg.BatchSize <= 0 {
+		d.cfg.BatchSize = 20
+	}
+	d.guard = NewGuard(d.cfg.AllowedHosts)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// через прокси Guard проверял бы адрес прокси, а не получателя
+	transport.Proxy = nil
+	transport.DialContext = d.guard.DialContext
+	d.client = &http.Client{
+		Timeout:   d.cfg.Timeout,
+		Transport: transport,
+		// редирект считается неуспешной доставкой
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	return d
+}
+
+// SetClient подменяет HTTP-клиент (свой TLS). Проверку адресов получателей
+// при соединении клиент должен делать сам (Guard.DialContext); через прокси
+// она проверит только адрес прокси.
+func (d *Dispatcher) SetClient(c *http.Client) {
+	d.client = c
+}
+
+// CheckURL проверяет callback_url до создания задачи: уведомления на
+// внутренние адреса не из AllowedHosts не отправляются.
+func (d *Dispatcher) CheckURL(ctx context.Context, raw string) error {
+	return d.guard.CheckURL(ctx, raw)
+}
+
+// Notify создаёт доставку для завершённой задачи, если у неё есть callback_url.
+// Повторный вызов для той же задачи и события ничего не делает.
+func (d *Dispatcher) Notify(ctx context.Context, taskID string) {
+	if err := d.enqueue(ctx, taskID); err != nil && !errors.Is(err, ErrNoCallback) {
+		d.log.Error("enqueue delivery", "task_id", taskID, "err", err)
+	}
+}
+
+func (d *Dispatcher) enqueue(ctx context.Context, taskID string) error {
+	t, err := d.store.Get(ctx, taskstore.AnyTenant, taskID)
+	if err != nil {
+		return err
+	}
+	if t.CallbackURL == nil {
+		return ErrNoCallback
+	}
+	if !t.Terminal() {
+		return ErrNotFinished
+	}
+
+	return d.insert(ctx, t, Payload{Event: "task." + string(t.Status)}, "")
+}
+
+// NotifyVulns создаёт доставку task.vulnerabilities с новыми находками
+// задачи, если у неё есть callback_url. dedupKey отличает доставки одной
+// задачи по разным снимкам базы.
+func (d *Dispatcher) NotifyVulns(ctx context.Context, taskID, snapshot, dedupKey string, findings []vulndb.Finding) {
+	t, err := d.store.Get(ctx, taskstore.AnyTenant, taskID)
+	if err != nil {
+		d.log.Error("enqueue delivery", "task_id", taskID, "event", EventVulnerabilities, "err", err)
+		return
+	}
+	if t.CallbackURL == nil {
+		return
+	}
+	p := Payload{Event: EventVulnerabilities, Snapshot: snapshot, Vulnerabilities: findings}
+	if err := d.insert(ctx, t, p, dedupKey); err != nil {
+		d.log.Error("enqueue delivery", "task_id", taskID, "event", EventVulnerabilities, "err", err)
+	}
+}
+
+// insert дополняет p данными задачи и сохраняет доставку; повтор той же
+// задачи, события и dedupKey ничего не делает.
+func (d *Dispatcher) insert(ctx context.Context, t taskstore.Task, p Payload, dedupKey string) error {
+	p.DeliveryID = uuid.NewString()
+	p.Task = TaskInfo{
+		ZipID:    t.ID,
+		Status:   string(t.Status),
+		Error:    t.Error,
+		Project:  t.Project,
+		Version:  t.ProjectVersion,
+		Packages: t.Packages,
+		TS:       t.Timestamp,
+	}
+	if t.Status == taskstore.StatusDone {
+		p.Task.Policy = t.PolicyVerdict
+	}
+	p.CreatedAt = time.Now().UTC()
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.db.ExecContext(ctx, `
+		INSERT INTO sbom_webhook_deliveries(id, task_id, event, url, payload, dedup_key)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (task_id, event, dedup_key) DO NOTHING
+	`, p.DeliveryID, t.ID, p.Event, *t.CallbackURL, body, dedupKey)
+	return err
+}
+
+// Redeliver ставит все доставки задачи на немедленную повторную отправку.
+// Если доставок ещё нет (задача завершилась до появления webhook), создаёт их.
+func (d *Dispatcher) Redeliver(ctx context.Context, taskID string) error {
+	t, err := d.store.Get(ctx, taskstore.AnyTenant, taskID)
+	if err != nil {
+		return err
+	}
+	if t.CallbackURL == nil {
+		return ErrNoCallback
+	}
+	if !t.Terminal() {
+		return ErrNotFinished
+	}
+
+	res, err := d.db.ExecContext(ctx, `
+		UPDATE sbom_webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = now()
+		WHERE task_id = $1
+	`, taskID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+	return d.enqueue(ctx, taskID)
+}
+
+func (d *Dispatcher) List(ctx context.Context, taskID string) ([]Delivery, error) {
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT id::text, task_id::text, event, url, status::text, attempts, next_attempt_at,
+		       last_status_code, last_error, created_at, delivered_at, payload
+		FROM sbom_webhook_deliveries
+		WHERE task_id = $1
+		ORDER BY created_at ASC
+	`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Delivery{}
+	for rows.Next() {
+		var dl Delivery
+		var code sql.NullInt64
+		var lastErr sql.NullString
+		var deliveredAt sql.NullTime
+		var payload []byte
+		if err := rows.Scan(&dl.ID, &dl.TaskID, &dl.Event, &dl.URL, &dl.Status, &dl.Attempts, &dl.NextAttemptAt,
+			&code, &lastErr, &dl.CreatedAt, &deliveredAt, &payload); err != nil {
+			return nil, err
+		}
+		if code.Valid {
+			v := int(code.Int64)
+			dl.LastStatusCode = &v
+		}
+		if lastErr.Valid {
+			dl.LastError = &lastErr.String
+		}
+		if deliveredAt.Valid {
+			dl.DeliveredAt = &deliveredAt.Time
+		}
+		dl.Payload = payload
+		out = append(out, dl)
+	}
+	return out, rows.Err()
+}
+
+func (d *Dispatcher) Start(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.Every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.RunOnce(ctx)
+		}
+	}
+}
+
+type claimed struct {
+	id       string
+	taskID   string
+	event    string
+	url      string
+	payload  []byte
+	attempts int
+	secret   sql.NullString
+}
+
+func (d *Dispatcher) RunOnce(ctx context.Context) {
+	items, err := d.claimDue(ctx)
+	if err != nil {
+		d.log.Error("claim deliveries", "err", err)
+		return
+	}
+	for _, it := range items {
+		code, err := d.send(ctx, it)
+		if ctx.Err() != nil {
+			return
+		}
+		if err := d.record(ctx, it, code, err); err != nil {
+			d.log.Error("record delivery", "delivery_id", it.id, "task_id", it.taskID, "err", err)
+		}
+	}
+}
+
+// claimDue забирает доставки, которым пора уйти, и сдвигает их next_attempt_at
+// на время отправки, чтобы другие реплики их не взяли.
+func (d *Dispatcher) claimDue(ctx context.Context) ([]claimed, error) {
+	lease := int64((2 * d.cfg.Timeout).Seconds())
+	rows, err := d.db.QueryContext(ctx, `
+		UPDATE sbom_webhook_deliveries d
+		SET attempts = d.attempts + 1,
+		    next_attempt_at = now() + ($2 * interval '1 second')
+		FROM sbom_tasks t
+		WHERE t.id = d.task_id
+		  AND d.id IN (
+			SELECT id
+			FROM sbom_webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at ASC
+			FOR UPDATE SKIP LOCKED
+			LIMIT $1
+		  )
+		RETURNING d.id::text, d.task_id::text, d.event, d.url, d.payload, d.attempts, t.callback_secret
+	`, d.cfg.BatchSize, lease)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []claimed
+	for rows.Next() {
+		var it claimed
+		if err := rows.Scan(&it.id, &it.taskID, &it.event, &it.url, &it.payload, &it.attempts, &it.secret); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+func (d *Dispatcher) send(ctx context.Context, it claimed) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, it.url, bytes.NewReader(it.payload))
+	if err != nil {
+		return 0, err
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "sbom-serv-webhook")
+	req.Header.Set(HeaderEvent, it.event)
+	req.Header.Set(HeaderDelivery, it.id)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	if it.secret.Valid && it.secret.String != "" {
+		req.Header.Set(HeaderSignature, Sign([]byte(it.secret.String), ts, it.payload))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) record(ctx context.Context, it claimed, code int, sendErr error) error {
+	var codeArg any
+	if code != 0 {
+		codeArg = code
+	}
+
+	if sendErr == nil {
+		_, err := d.db.ExecContext(ctx, `
+			UPDATE sbom_webhook_deliveries
+			SET status = 'delivered', delivered_at = now(), last_status_code = $2, last_error = NULL
+			WHERE id = $1
+		`, it.id, codeArg)
+		return err
+	}
+
+	msg := sendErr.Error()
+	delay, retry := d.retryAfter(it.attempts)
+	if !retry {
+		d.log.Warn("delivery failed, giving up", "delivery_id", it.id, "task_id", it.taskID,
+			"attempts", it.attempts, "status_code", code, "err", msg)
+		_, err := d.db.ExecContext(ctx, `
+			UPDATE sbom_webhook_deliveries
+			SET status = 'failed', last_status_code = $2, last_error = $3
+			WHERE id = $1
+		`, it.id, codeArg, msg)
+		return err
+	}
+
+	_, err := d.db.ExecContext(ctx, `
+		UPDATE sbom_webhook_deliveries
+		SET next_attempt_at = now() + ($2 * interval '1 millisecond'),
+		    last_status_code = $3, last_error = $4
+		WHERE id = $1
+	`, it.id, delay.Milliseconds(), codeArg, msg)
+	return err
+}
+
+// retryAfter — через сколько повторить доставку после неудачной попытки
+// attempt; false — попытки исчерпаны.
+func (d *Dispatcher) retryAfter(attempt int) (time.Duration, bool) {
+	if attempt >= d.cfg.MaxAttempts {
+		return 0, false
+	}
+	return d.backoff(attempt), true
+}
+
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.cfg.BaseBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= d.cfg.MaxBackoff {
+			return d.cfg.MaxBackoff
+		}
+	}
+	return delay
+}
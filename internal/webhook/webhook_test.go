@@ -0,0 +1,112 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSendToStandIn(t *testing.T) {
+	secret := "s3cret"
+	payload := []byte(`{"event":"task.done"}`)
+
+	var mu sync.Mutex
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if err != nil {
+			t.Errorf("bad %s: %v", HeaderTimestamp, err)
+		}
+		if !Verify([]byte(secret), ts, body, r.Header.Get(HeaderSignature)) {
+			t.Errorf("bad signature %q", r.Header.Get(HeaderSignature))
+		}
+		if got := r.Header.Get(HeaderEvent); got != "task.done" {
+			t.Errorf("%s = %q", HeaderEvent, got)
+		}
+
+		mu.Lock()
+		calls++
+		n := calls
+		mu.Unlock()
+		if n < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	d := New(nil, nil, Config{
+		MaxAttempts:  5,
+		BaseBackoff:  10 * time.Millisecond,
+		MaxBackoff:   15 * time.Millisecond,
+		AllowedHosts: []string{"127.0.0.1"},
+	})
+	if err := d.CheckURL(context.Background(), srv.URL); err != nil {
+		t.Fatalf("CheckURL(%s): %v", srv.URL, err)
+	}
+
+	it := claimed{id: "d1", taskID: "t1", event: "task.done", url: srv.URL, payload: payload}
+	it.secret.String, it.secret.Valid = secret, true
+
+	// как RunOnce: 5xx — неудачная попытка, повтор с растущей задержкой
+	wantDelays := []time.Duration{10 * time.Millisecond, 15 * time.Millisecond}
+	for attempt := 1; ; attempt++ {
+		it.attempts = attempt
+		code, err := d.send(context.Background(), it)
+		if err == nil {
+			if code != http.StatusNoContent || attempt != 3 {
+				t.Fatalf("delivered on attempt %d with %d, want 3 with 204", attempt, code)
+			}
+			break
+		}
+		if code != http.StatusBadGateway {
+			t.Fatalf("attempt %d: code %d, err %v", attempt, code, err)
+		}
+		delay, retry := d.retryAfter(attempt)
+		if !retry || delay != wantDelays[attempt-1] {
+			t.Fatalf("attempt %d: retryAfter = %v, %v; want %v, true", attempt, delay, retry, wantDelays[attempt-1])
+		}
+	}
+
+	if _, retry := d.retryAfter(5); retry {
+		t.Error("retryAfter(MaxAttempts) should give up")
+	}
+}
+
+func TestGuardRejectsLoopback(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	d := New(nil, nil, Config{})
+	if err := d.CheckURL(context.Background(), srv.URL); !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("CheckURL(%s) = %v, want ErrForbiddenAddress", srv.URL, err)
+	}
+	// и при соединении, даже если адрес прошёл проверку при загрузке
+	_, err := d.send(context.Background(), claimed{id: "d1", taskID: "t1", event: "task.done", url: srv.URL})
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("send = %v, want ErrForbiddenAddress", err)
+	}
+	if called {
+		t.Error("stand-in received a request")
+	}
+
+	for _, raw := range []string{"ftp://example.com/x", "http://[::1]:8080/", "http://10.1.2.3/"} {
+		if err := NewGuard(nil).CheckURL(context.Background(), raw); err == nil {
+			t.Errorf("CheckURL(%s) = nil", raw)
+		}
+	}
+	if err := NewGuard([]string{"10.0.0.0/8"}).CheckURL(context.Background(), "http://10.1.2.3/"); err != nil {
+		t.Errorf("allowed subnet: %v", err)
+	}
+}
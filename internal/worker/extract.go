@@ -0,0 +1,104 @@
+package worker
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+type archiveInfo struct {
+	Files int
+	Size  uint64
+}
+
+// validateZip проверяет центральный каталог архива и имена файлов
+// до распаковки: битый zip, path traversal или слишком большой архив должны
+// падать сразу. maxFiles и maxSize <= 0 — без ограничения.
+func validateZip(zr *zip.Reader, maxFiles int, maxSize int64) (archiveInfo, error) {
+	var info archiveInfo
+	for _, f := range zr.File {
+		name := filepath.FromSlash(f.Name)
+		if !filepath.IsLocal(name) {
+			return archiveInfo{}, fmt.Errorf("unsafe path in archive: %q", f.Name)
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		info.Files++
+		info.Size += f.UncompressedSize64
+	}
+	if info.Files == 0 {
+		return archiveInfo{}, errors.New("archive contains no files")
+	}
+	if maxFiles > 0 && info.Files > maxFiles {
+		return archiveInfo{}, fmt.Errorf("archive contains %d files, limit is %d", info.Files, maxFiles)
+	}
+	if maxSize > 0 && info.Size > uint64(maxSize) {
+		return archiveInfo{}, fmt.Errorf("archive unpacks to %d bytes, limit is %d", info.Size, maxSize)
+	}
+	return info, nil
+}
+
+// extractZip распаковывает архив в dst. onProgress получает количество уже
+// записанных байт. Символические ссылки и специальные файлы пропускаются.
+func extractZip(ctx context.Context, zr *zip.Reader, dst string, onProgress func(written uint64)) error {
+	var written uint64
+	for _, f := range zr.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, filepath.FromSlash(f.Name))
+		mode := f.Mode()
+
+		if mode.IsDir() {
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+		if !mode.IsRegular() {
+			continue
+		}
+
+		n, err := extractFile(f, target)
+		written += n
+		if err != nil {
+			return fmt.Errorf("extract %s: %w", f.Name, err)
+		}
+		onProgress(written)
+	}
+	return nil
+}
+
+func extractFile(f *zip.File, target string) (uint64, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return 0, err
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return 0, err
+	}
+
+	// заголовок архива может врать о размере — не даём записать больше заявленного
+	n, copyErr := io.Copy(out, io.LimitReader(rc, int64(f.UncompressedSize64)+1))
+	closeErr := out.Close()
+	if copyErr != nil {
+		return uint64(n), copyErr
+	}
+	if uint64(n) > f.UncompressedSize64 {
+		return uint64(n), errors.New("file is larger than declared in archive header")
+	}
+	return uint64(n), closeErr
+}
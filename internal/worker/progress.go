@@ -0,0 +1,81 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"sbom-serv/internal/taskstore"
+	"sbom-serv/internal/tracing"
+)
+
+// Как часто можно писать процент выполнения в БД
+const progressEvery = time.Second
+
+// reporter сохраняет этап и прогресс задачи. Ошибки записи не прерывают
+// обработку (прогресс — только подсказка для клиента), но попадают в лог.
+type reporter struct {
+	ctx   context.Context
+	store *taskstore.Store
+	id    string
+	log   *slog.Logger
+	// Текущий этап: по нему классифицируется причина сбоя
+	stage taskstore.Stage
+	// Спан текущего этапа
+	span trace.Span
+
+	lastPercent int
+	lastAt      time.Time
+}
+
+func newReporter(ctx context.Context, store *taskstore.Store, id string, log *slog.Logger) *reporter {
+	return &reporter{ctx: ctx, store: store, id: id, log: log, lastPercent: -1}
+}
+
+func (r *reporter) Stage(stage taskstore.Stage) {
+	if r.span != nil {
+		r.span.End()
+	}
+	r.stage = stage
+	_, r.span = tracing.Start(r.ctx, "scan."+string(stage))
+	r.log.Debug("task stage", "stage", stage)
+	r.check("set stage", r.store.SetStage(r.ctx, r.id, stage))
+	r.lastPercent = -1
+	r.lastAt = time.Time{}
+}
+
+func (r *reporter) Progress(percent int) {
+	if percent > 100 {
+		percent = 100
+	}
+	if percent == r.lastPercent {
+		return
+	}
+	if percent < 100 && time.Since(r.lastAt) < progressEvery {
+		return
+	}
+	r.check("set progress", r.store.SetProgress(r.ctx, r.id, percent))
+	r.lastPercent = percent
+	r.lastAt = time.Now()
+}
+
+func (r *reporter) Packages(n int) {
+	r.check("set packages", r.store.SetPackages(r.ctx, r.id, n))
+}
+
+// Done завершает спан последнего этапа; ошибка относится к нему.
+func (r *reporter) Done(err error) {
+	if r.span != nil {
+		tracing.End(r.span, err)
+		r.span = nil
+	}
+}
+
+func (r *reporter) check(op string, err error) {
+	// после отмены задачи ошибки записи ожидаемы
+	if err != nil && r.ctx.Err() == nil {
+		r.log.Warn(op, "stage", r.stage, "err", err)
+	}
+}
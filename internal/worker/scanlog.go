@@ -0,0 +1,114 @@
+package worker
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// Сколько последних байт stderr попадает в sbom_tasks.error
+	maxErrorSummary = 2048
+)
+
+// scanLog пишет диагностику сканера в файл задачи (с ограничением по размеру)
+// и параллельно хранит хвост stderr для короткого сообщения об ошибке.
+type scanLog struct {
+	f    *os.File
+	max  int64
+	lim  *limitedWriter
+	tail *tailBuffer
+}
+
+func openScanLog(path string, maxBytes int64) (*scanLog, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	var used int64
+	if info, err := f.Stat(); err == nil {
+		used = info.Size()
+	}
+	return &scanLog{
+		f:    f,
+		max:  maxBytes,
+		lim:  &limitedWriter{w: f, n: maxBytes - used},
+		tail: &tailBuffer{max: maxErrorSummary},
+	}, nil
+}
+
+// Output — writer для stderr/stdout сканера.
+func (l *scanLog) Output() io.Writer {
+	return io.MultiWriter(l.lim, l.tail)
+}
+
+func (l *scanLog) Printf(format string, args ...any) {
+	line := time.Now().UTC().Format(time.RFC3339) + " " + fmt.Sprintf(format, args...)
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+	_, _ = l.lim.Write([]byte(line))
+}
+
+// Summary возвращает хвост вывода сканера для поля error.
+func (l *scanLog) Summary() string {
+	s := strings.TrimSpace(string(l.tail.buf))
+	if l.tail.cut {
+		s = "..." + s
+	}
+	return s
+}
+
+func (l *scanLog) Close() error {
+	if l.lim.truncated {
+		// служебная строка пишется в обход лимита
+		_, _ = fmt.Fprintf(l.f, "\n[log truncated at %d bytes]\n", l.max)
+	}
+	return l.f.Close()
+}
+
+type limitedWriter struct {
+	w         io.Writer
+	n         int64
+	truncated bool
+}
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	if lw.n <= 0 {
+		lw.truncated = len(p) > 0 || lw.truncated
+		return len(p), nil
+	}
+	chunk := p
+	if int64(len(chunk)) > lw.n {
+		chunk = chunk[:lw.n]
+		lw.truncated = true
+	}
+	n, err := lw.w.Write(chunk)
+	lw.n -= int64(n)
+	if err != nil {
+		return n, err
+	}
+	return len(p), nil
+}
+
+type tailBuffer struct {
+	buf []byte
+	max int
+	cut bool
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	if len(p) >= t.max {
+		t.cut = t.cut || len(t.buf) > 0 || len(p) > t.max
+		t.buf = append(t.buf[:0], p[len(p)-t.max:]...)
+		return len(p), nil
+	}
+	t.buf = append(t.buf, p...)
+	if over := len(t.buf) - t.max; over > 0 {
+		t.buf = append(t.buf[:0], t.buf[over:]...)
+		t.cut = true
+	}
+	return len(p), nil
+}
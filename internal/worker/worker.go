@@ -1,49 +1,266 @@
 package worker
 
 import (
-	"bytes"
+	"archive/zip"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sbom-serv/internal/config"
+	"sbom-serv/internal/logging"
+	"sbom-serv/internal/metrics"
+	"sbom-serv/internal/sbom"
 	"sbom-serv/internal/taskstore"
+	"sbom-serv/internal/tracing"
+	"strings"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func processTask(ctx context.Context, zipPath, resultPath string) error {
-	tmp := resultPath + ".tmp"
+type Config struct {
+	// Сколько задач обрабатывается одновременно
+	Parallel int
+
+	// Как часто проверять очередь
+	PollEvery time.Duration
+
+	// Путь к syft
+	SyftPath string
+
+	// Ограничение времени обработки одной задачи (0 = без ограничения)
+	ScanTimeout time.Duration
+
+	// Максимальный размер лога одной задачи на диске
+	MaxLogBytes int64
+
+	// Ограничения на распакованный архив (0 = без ограничения)
+	MaxUnpackedBytes int64
+	MaxFiles         int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Parallel:    5,
+		PollEvery:   300 * time.Millisecond,
+		SyftPath:    "syft",
+		MaxLogBytes: 1 << 20,
+	}
+}
+
+type Worker struct {
+	store     *taskstore.Store
+	paths     config.UploadPaths
+	cfg       Config
+	onFinish  []FinishFunc
+	analyzers []analyzer
+	busy      atomic.Int64
+	log       *slog.Logger
+}
+
+func New(store *taskstore.Store, paths config.UploadPaths, cfg Config) *Worker {
+	w := &Worker{
+		store: store,
+		paths: paths,
+		cfg:   cfg,
+		log:   logging.Component("worker"),
+	}
+	def := DefaultConfig()
+	if w.cfg.Parallel <= 0 {
+		w.cfg.Parallel = def.Parallel
+	}
+	if w.cfg.PollEvery <= 0 {
+		w.cfg.PollEvery = def.PollEvery
+	}
+	if w.cfg.SyftPath == "" {
+		w.cfg.SyftPath = def.SyftPath
+	}
+	if w.cfg.MaxLogBytes <= 0 {
+		w.cfg.MaxLogBytes = def.MaxLogBytes
+	}
+	return w
+}
+
+// Capacity — сколько задач воркер может обрабатывать одновременно.
+func (w *Worker) Capacity() int { return w.cfg.Parallel }
+
+// Busy — сколько задач обрабатывается сейчас.
+func (w *Worker) Busy() int { return int(w.busy.Load()) }
+
+// FinishFunc вызывается после перевода задачи в done или failed.
+type FinishFunc func(ctx context.Context, id string)
+
+// OnFinish регистрирует обработчик завершения задачи.
+func (w *Worker) OnFinish(fn FinishFunc) {
+	w.onFinish = append(w.onFinish, fn)
+}
+
+// AnalyzeFunc — этап анализа готового SBOM (уязвимости, политики).
+// Ошибка этапа из Analyze проваливает задачу.
+type AnalyzeFunc func(ctx context.Context, id string, doc *sbom.Document) error
+
+type analyzer struct {
+	stage    taskstore.Stage
+	fn       AnalyzeFunc
+	optional bool
+}
+
+// Analyze добавляет этап анализа после конвертации результата.
+// Этапы выполняются в порядке регистрации.
+func (w *Worker) Analyze(stage taskstore.Stage, fn AnalyzeFunc) {
+	w.analyzers = append(w.analyzers, analyzer{stage: stage, fn: fn})
+}
+
+// Enrich добавляет этап, без которого задачу можно завершить: ошибка только
+// пишется в лог, этап повторяется позже по отметке в БД (vulnwatch,
+// vulndb rematch, packages reindex).
+func (w *Worker) Enrich(stage taskstore.Stage, fn AnalyzeFunc) {
+	w.analyzers = append(w.analyzers, analyzer{stage: stage, fn: fn, optional: true})
+}
+
+type taskFiles struct {
+	Zip    string
+	Result string
+	Log    string
+	Work   string
+}
+
+func (w *Worker) processTask(ctx context.Context, files taskFiles, rep *reporter) error {
+	tlog, err := openScanLog(files.Log, w.cfg.MaxLogBytes)
+	if err != nil {
+		return fmt.Errorf("open scan log: %w", err)
+	}
+	defer tlog.Close()
+
+	rep.Stage(taskstore.StageValidating)
+	zr, err := zip.OpenReader(files.Zip)
+	if err != nil {
+		tlog.Printf("invalid archive: %v", err)
+		return fmt.Errorf("invalid zip: %w", err)
+	}
+	defer zr.Close()
+
+	info, err := validateZip(&zr.Reader, w.cfg.MaxFiles, w.cfg.MaxUnpackedBytes)
+	if err != nil {
+		tlog.Printf("invalid archive: %v", err)
+		return fmt.Errorf("invalid zip: %w", err)
+	}
+	tlog.Printf("archive: %d files, %d bytes uncompressed", info.Files, info.Size)
+
+	rep.Stage(taskstore.StageExtracting)
+	_ = os.RemoveAll(files.Work)
+	defer os.RemoveAll(files.Work)
+	if err := os.MkdirAll(files.Work, 0o755); err != nil {
+		return err
+	}
+	err = extractZip(ctx, &zr.Reader, files.Work, func(written uint64) {
+		if info.Size > 0 {
+			rep.Progress(int(written * 100 / info.Size))
+		}
+	})
+	if err != nil {
+		tlog.Printf("extract failed: %v", err)
+		return err
+	}
+
+	rep.Stage(taskstore.StageCataloging)
+	tmp := files.Result + ".tmp"
 	out, err := os.Create(tmp)
 	if err != nil {
 		return err
 	}
 	defer out.Close()
 
-	cmd := exec.CommandContext(ctx, "syft", zipPath, "-o", "json")
+	cmd := exec.CommandContext(ctx, w.cfg.SyftPath, "dir:"+files.Work, "-o", "json")
 
 	cmd.Stdout = out
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
+	cmd.Stderr = tlog.Output()
+
+	started := time.Now()
+	tlog.Printf("start: %s", strings.Join(cmd.Args, " "))
 
 	if err := cmd.Run(); err != nil {
 		_ = os.Remove(tmp)
-		return fmt.Errorf("syft failed: %v: %s", err, stderr.String())
+		tlog.Printf("finish: %v (%s)", err, time.Since(started).Round(time.Millisecond))
+		if summary := tlog.Summary(); summary != "" {
+			return fmt.Errorf("syft failed: %v: %s", err, summary)
+		}
+		return fmt.Errorf("syft failed: %v", err)
 	}
+	tlog.Printf("finish: ok (%s)", time.Since(started).Round(time.Millisecond))
 
 	if err := out.Close(); err != nil {
 		_ = os.Remove(tmp)
 		return err
 	}
 
-	return os.Rename(tmp, resultPath)
+	rep.Stage(taskstore.StageConverting)
+	doc, err := sbom.ReadFile(tmp)
+	if err != nil {
+		_ = os.Remove(tmp)
+		tlog.Printf("invalid scanner output: %v", err)
+		return err
+	}
+	rep.Packages(len(doc.Artifacts))
+	tlog.Printf("packages: %d", len(doc.Artifacts))
+
+	for _, a := range w.analyzers {
+		rep.Stage(a.stage)
+		err := a.fn(ctx, rep.id, doc)
+		if err != nil && a.optional && ctx.Err() == nil {
+			tlog.Printf("%s failed, will be retried later: %v", a.stage, err)
+			w.log.Warn("analyzer failed", "task_id", rep.id, "stage", a.stage, "err", err)
+			continue
+		}
+		if err != nil {
+			_ = os.Remove(tmp)
+			tlog.Printf("%s failed: %v", a.stage, err)
+			return fmt.Errorf("%s: %w", a.stage, err)
+		}
+	}
+
+	rep.Stage(taskstore.StageStoring)
+	return os.Rename(tmp, files.Result)
 }
-func StartWorker(ctx context.Context, store *taskstore.Store, paths config.UploadPaths, maxParallel int) {
+
+// Как часто воркер проверяет, не отменили ли выполняемую задачу
+const watchEvery = 5 * time.Second
+
+// watchTask останавливает обработку, когда задача перестала быть running
+// (отмена, удаление, janitor).
+func watchTask(ctx context.Context, cancel context.CancelFunc, store *taskstore.Store, id string) {
+	ticker := time.NewTicker(watchEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t, err := store.Get(ctx, taskstore.AnyTenant, id)
+			if errors.Is(err, sql.ErrNoRows) || (err == nil && t.Status != taskstore.StatusRunning) {
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+func (w *Worker) Start(ctx context.Context) {
+	maxParallel := w.cfg.Parallel
 	sem := make(chan struct{}, maxParallel)
 
-	ticker := time.NewTicker(300 * time.Millisecond)
+	ticker := time.NewTicker(w.cfg.PollEvery)
 	defer ticker.Stop()
 
+	var claimFailing bool
 	for {
 		select {
 		case <-ctx.Done():
@@ -66,32 +283,182 @@ func StartWorker(ctx context.Context, store *taskstore.Store, paths config.Uploa
 				continue
 			}
 
-			task, ok, err := store.ClaimNextQueued(ctx)
+			claimStarted := time.Now()
+			task, ok, err := w.store.ClaimNextQueued(ctx)
 			if err != nil {
 				<-sem
+				// при недоступной БД не пишем ошибку на каждый тик
+				if !claimFailing && ctx.Err() == nil {
+					w.log.Error("claim task", "err", err)
+				}
+				claimFailing = true
 				continue
 			}
+			if claimFailing {
+				w.log.Info("claim task: database is available again")
+				claimFailing = false
+			}
 			if !ok {
 				<-sem
 				continue
 			}
 
+			metrics.QueueWait.Observe(task.QueueWait.Seconds())
+
 			id := task.ID
-			zipPath := filepath.Join(paths.Zips, "zip-"+id+".zip")
-			resultPath := filepath.Join(paths.Results, "result-"+id+".json")
+			tp := w.paths.Tenant(task.Tenant)
+			files := taskFiles{
+				Zip:    filepath.Join(tp.Zips, "zip-"+id+".zip"),
+				Result: tp.ResultPath(id),
+				Log:    tp.LogPath(id),
+				Work:   tp.WorkDir(id),
+			}
 
-			go func(id, zipPath, resultPath string) {
+			w.busy.Add(1)
+			go func(task taskstore.Task, files taskFiles) {
 				defer func() { <-sem }()
+				defer w.busy.Add(-1)
+				w.run(ctx, task, files, claimStarted)
+			}(task, files)
+		}
+	}
+}
 
-				if err := processTask(ctx, zipPath, resultPath); err != nil {
-					msg := err.Error()
-					_ = store.SetStatus(ctx, id, taskstore.StatusFailed, &msg)
-					return
-				}
+// taskLogger — логгер с полями, по которым задачу находят в логах.
+func (w *Worker) taskLogger(t taskstore.Task) *slog.Logger {
+	l := w.log.With("task_id", t.ID, "tenant", t.Tenant)
+	if t.RequestID != nil {
+		l = l.With("request_id", *t.RequestID)
+	}
+	return l
+}
+
+// startSpan открывает корневой спан обработки задачи со ссылкой на спан
+// запроса загрузки и добавляет к нему спан захвата задачи из очереди.
+// Пустые опросы очереди не трассируются, поэтому claim записывается задним числом.
+func startSpan(ctx context.Context, task taskstore.Task, claimStarted time.Time) (context.Context, trace.Span) {
+	opts := []trace.SpanStartOption{
+		trace.WithTimestamp(claimStarted),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("task_id", task.ID),
+			attribute.String("tenant", task.Tenant),
+			attribute.Float64("queue_wait_seconds", task.QueueWait.Seconds()),
+		),
+	}
+	if task.TraceParent != nil {
+		if link, ok := tracing.LinkTo(*task.TraceParent); ok {
+			opts = append(opts, trace.WithLinks(link))
+		}
+	}
+	ctx, span := tracing.Start(ctx, "worker.task", opts...)
 
-				_ = os.Remove(zipPath)
-				_ = store.SetStatus(ctx, id, taskstore.StatusDone, nil)
-			}(id, zipPath, resultPath)
+	_, claim := tracing.Start(ctx, "worker.claim", trace.WithTimestamp(claimStarted))
+	claim.End()
+	return ctx, span
+}
+
+func (w *Worker) run(ctx context.Context, task taskstore.Task, files taskFiles, claimStarted time.Time) {
+	id := task.ID
+	ctx, span := startSpan(ctx, task, claimStarted)
+	defer span.End()
+
+	log := w.taskLogger(task)
+	if sc := span.SpanContext(); sc.IsValid() {
+		log = log.With("trace_id", sc.TraceID().String())
+	}
+	log.Info("task started", "queue_wait", task.QueueWait)
+
+	var taskCtx context.Context
+	var cancel context.CancelFunc
+	if w.cfg.ScanTimeout > 0 {
+		taskCtx, cancel = context.WithTimeout(ctx, w.cfg.ScanTimeout)
+	} else {
+		taskCtx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+	go watchTask(taskCtx, cancel, w.store, id)
+
+	status := taskstore.StatusDone
+	var errMsg *string
+	var reason string
+	started := time.Now()
+
+	rep := newReporter(taskCtx, w.store, id, log)
+	err := w.processTask(taskCtx, files, rep)
+	rep.Done(err)
+	if err != nil {
+		msg := err.Error()
+		reason = string(rep.stage)
+		if reason == "" {
+			reason = "internal"
+		}
+		if errors.Is(taskCtx.Err(), context.DeadlineExceeded) {
+			msg = fmt.Sprintf("scan timed out after %s: %s", w.cfg.ScanTimeout, msg)
+			reason = "timeout"
+		}
+		status, errMsg = taskstore.StatusFailed, &msg
+	}
+
+	span.SetAttributes(attribute.String("status", string(status)))
+	if errMsg != nil {
+		span.SetStatus(codes.Error, reason)
+	}
+
+	finishCtx, finishSpan := tracing.Start(ctx, "worker.finish")
+	finished, err := w.finish(finishCtx, id, status, errMsg)
+	tracing.End(finishSpan, err)
+	if err != nil {
+		// задача останется running, и её подберёт janitor
+		log.Error("finish task", "status", status, "err", err)
+		return
+	}
+	if !finished {
+		// задачу отменили, удалили или janitor вернул её в очередь
+		log.Info("task no longer running, result discarded", "status", status)
+		return
+	}
+	if errMsg != nil {
+		log.Warn("task failed", "reason", reason, "stage", rep.stage, "duration", time.Since(started), "err", *errMsg)
+	} else {
+		log.Info("task done", "duration", time.Since(started))
+	}
+	metrics.ScanDuration.WithLabelValues(string(status)).Observe(time.Since(started).Seconds())
+	if status == taskstore.StatusDone {
+		metrics.TasksCompleted.Inc()
+	} else {
+		metrics.TasksFailed.WithLabelValues(reason).Inc()
+	}
+	if status == taskstore.StatusDone {
+		_ = os.Remove(files.Zip)
+	}
+	for _, fn := range w.onFinish {
+		fn(ctx, id)
+	}
+}
+
+// finish сохраняет итог задачи, повторяя запись при временных ошибках БД:
+// иначе результат сканирования теряется до прихода janitor.
+func (w *Worker) finish(ctx context.Context, id string, status taskstore.Status, errMsg *string) (bool, error) {
+	const attempts = 3
+	backoff := time.Second
+
+	var err error
+	for i := 0; i < attempts; i++ {
+		var finished bool
+		if finished, err = w.store.Finish(ctx, id, status, errMsg); err == nil {
+			return finished, nil
+		}
+		if i == attempts-1 {
+			break
+		}
+		w.log.Warn("finish task, retrying", "task_id", id, "attempt", i+1, "err", err)
+		select {
+		case <-ctx.Done():
+			return false, err
+		case <-time.After(backoff):
 		}
+		backoff *= 2
 	}
+	return false, err
 }